@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+)
+
+// atomicWriter writes a file under a temporary name, and only renames it to
+// its own name in Commit, so a run that fails or is interrupted never leaves
+// a half written list that looks valid. A name of "-" writes to stdout
+// instead, directly.
+type atomicWriter struct {
+	f    *os.File
+	name string
+	done bool
+}
+
+// tempName is the name a file is written under until it's complete.
+func tempName(name string) string {
+	return name + ".tmp"
+}
+
+// createAtomic creates the temporary file for name, which can be "-" for
+// stdout.
+func createAtomic(name string) (*atomicWriter, error) {
+	if name == "-" {
+		return &atomicWriter{f: os.Stdout}, nil
+	}
+	f, err := os.Create(tempName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &atomicWriter{f: f, name: name}, nil
+}
+
+func (w *atomicWriter) Write(p []byte) (int, error) {
+	return w.f.Write(p)
+}
+
+// Commit closes the temporary file and renames it to its name.
+func (w *atomicWriter) Commit() error {
+	if w.name == "" || w.done {
+		return nil
+	}
+	w.done = true
+	err := w.f.Close()
+	if err == nil {
+		err = os.Rename(w.f.Name(), w.name)
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+	}
+	return err
+}
+
+// Close removes the temporary file, unless Commit was called, so it can be
+// deferred.
+func (w *atomicWriter) Close() error {
+	if w.name == "" || w.done {
+		return nil
+	}
+	w.done = true
+	w.f.Close()
+	return os.Remove(w.f.Name())
+}
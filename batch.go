@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// searchBatch looks up all hashes in hashesFile. The hashes are sorted, so
+// every list file only has to be read once, in a merge-style scan. With
+// binaryOutput only the found hashes are written, as raw bytes.
+func searchBatch(ctx context.Context, w io.Writer, hashesFile string, filenames []string, withCount bool, format pwnedlist.Format, skipBOM, lowercase, binaryOutput bool) error {
+	queries, err := readHashes(hashesFile, format.HashLen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitWith(exitError)
+	}
+	found := make([]bool, len(queries))
+	for _, filename := range filenames {
+		err = scanHashesFile(ctx, filename, queries, found, withCount, skipBOM, format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error searching file %q: %v\n", filename, err)
+			return exitWith(exitError)
+		}
+	}
+	anyFound := false
+	for i, q := range queries {
+		if binaryOutput {
+			if found[i] {
+				anyFound = true
+				if err := writeDigest(w, string(q)); err != nil {
+					fmt.Fprintln(os.Stderr, "error:", err)
+					return exitWith(exitError)
+				}
+			}
+			continue
+		}
+		if found[i] {
+			anyFound = true
+			fmt.Fprintf(w, "%s found\n", displayHash(string(q), lowercase))
+		} else {
+			fmt.Fprintf(w, "%s not-found\n", displayHash(string(q), lowercase))
+		}
+	}
+	if !anyFound {
+		return exitWith(exitNotFound)
+	}
+	return nil
+}
+
+// readHashes reads one hash of hashLen characters per line from filename, and
+// returns them sorted and without duplicates.
+func readHashes(filename string, hashLen int) ([][]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var hashes [][]byte
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := bytes.ToUpper(bytes.TrimSpace(s.Bytes()))
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) != hashLen || !pwnedlist.IsHex(line) {
+			return nil, fmt.Errorf("line %d of %q isn't a hash of %d hexadecimal characters", n, filename, hashLen)
+		}
+		hashes = append(hashes, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i], hashes[j]) < 0
+	})
+	unique := hashes[:0]
+	for i, h := range hashes {
+		if i == 0 || !bytes.Equal(h, hashes[i-1]) {
+			unique = append(unique, h)
+		}
+	}
+	return unique, nil
+}
+
+func scanHashesFile(ctx context.Context, filename string, queries [][]byte, found []bool, withCount, skipBOM bool, format pwnedlist.Format) error {
+	r, err := openStream(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	sr, _ := skipStreamBOM(r, skipBOM)
+	return format.SearchMany(pwnedlist.ContextReader(ctx, sr), queries, found, withCount)
+}
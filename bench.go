@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// benchOptions are the search modes bench measures.
+type benchOptions struct {
+	queries       int
+	warm          bool
+	useMmap       bool
+	interpolation bool
+	filter        *pwnedlist.BloomFilter
+	format        pwnedlist.Format
+	// seed seeds the random queries, so a run can be repeated with the same
+	// ones.
+	seed int64
+	// presentRatio is the fraction of queries taken from the list.
+	presentRatio float64
+}
+
+// bench times searchFile for random hashes in filename, opts.presentRatio of
+// which are taken from the list, and prints the latency percentiles.
+func bench(filename string, opts benchOptions) error {
+	rng := rand.New(rand.NewPCG(uint64(opts.seed), 0))
+	queries, err := benchQueries(rng, filename, opts.queries, opts.presentRatio, opts.format)
+	if err != nil {
+		return err
+	}
+	if opts.warm {
+		if err := warmUp(filename); err != nil {
+			return err
+		}
+	}
+	latencies := make([]time.Duration, len(queries))
+	found := 0
+	start := time.Now()
+	for i, hash := range queries {
+		t := time.Now()
+		match := int64(-1)
+		if opts.filter == nil || opts.filter.MayContain(hash) {
+			match, _, err = searchFile(context.Background(), filename, hash, opts.useMmap, opts.interpolation, false, nil, opts.format, nil)
+			if err != nil && !errors.Is(err, pwnedlist.ErrNotFound) {
+				return err
+			}
+		}
+		latencies[i] = time.Since(t)
+		if match != -1 {
+			found++
+		}
+	}
+	total := time.Since(start)
+	slices.Sort(latencies)
+	percentile := func(p int) time.Duration {
+		return latencies[(len(latencies)-1)*p/100]
+	}
+	fmt.Printf("seed        %d\n", opts.seed)
+	fmt.Printf("queries     %d (%d found)\n", len(queries), found)
+	fmt.Printf("total       %v\n", total)
+	fmt.Printf("throughput  %.0f queries/s\n", float64(len(queries))/total.Seconds())
+	fmt.Printf("p50         %v\n", percentile(50))
+	fmt.Printf("p95         %v\n", percentile(95))
+	fmt.Printf("p99         %v\n", percentile(99))
+	fmt.Printf("max         %v\n", latencies[len(latencies)-1])
+	return nil
+}
+
+// benchQueries returns n hashes in random order, taken from rng. presentRatio
+// of them are read from random records of the list, the rest are random.
+func benchQueries(rng *rand.Rand, filename string, n int, presentRatio float64, format pwnedlist.Format) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	records := fi.Size() / int64(format.RecordSize)
+	buf := make([]byte, format.HashLen)
+	random := make([]byte, format.HashLen/2)
+	queries := make([]string, n)
+	present := int(math.Round(float64(n) * presentRatio))
+	for i := range queries {
+		if i < present && records > 0 {
+			_, err := f.ReadAt(buf, rng.Int64N(records)*int64(format.RecordSize))
+			if err != nil {
+				return nil, err
+			}
+			queries[i] = string(buf)
+			continue
+		}
+		for j := range random {
+			random[j] = byte(rng.Uint32())
+		}
+		queries[i] = strings.ToUpper(hex.EncodeToString(random))
+	}
+	rng.Shuffle(len(queries), func(i, j int) {
+		queries[i], queries[j] = queries[j], queries[i]
+	})
+	return queries, nil
+}
+
+// warmUp reads all of filename, so it's in the page cache.
+func warmUp(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(io.Discard, f)
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// checkCheckpointEvery is how often check --checkpoint records its progress.
+const checkCheckpointEvery = 10 * time.Second
+
+// readCheckCheckpoint returns the number of records of filename that a check
+// with checkpoint already found valid, or 0 if checkpoint doesn't exist. The
+// checkpoint records the size of the list too, so it isn't used for another
+// one.
+func readCheckCheckpoint(checkpoint, filename string) (int, error) {
+	b, err := os.ReadFile(checkpoint)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var records int
+	var size int64
+	if _, err := fmt.Sscanf(string(b), "%d %d", &records, &size); err != nil || records < 0 {
+		return 0, fmt.Errorf("corrupt checkpoint file %q", checkpoint)
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return 0, err
+	}
+	if fi.Size() != size {
+		return 0, fmt.Errorf("checkpoint file %q is for a list of %d bytes, not %d", checkpoint, size, fi.Size())
+	}
+	return records, nil
+}
+
+// writeCheckCheckpoint replaces checkpoint, so it's never half written.
+func writeCheckCheckpoint(checkpoint, filename string, records int) error {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	tmp := checkpoint + ".tmp"
+	err = os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", records, fi.Size())), 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, checkpoint)
+}
+
+// checkpointProgress returns a ProgressFunc that calls progress, unless it's
+// nil, and at most every checkCheckpointEvery writes the number of valid
+// records to checkpoint, which is start plus the ones checked so far.
+func checkpointProgress(checkpoint, filename string, start int, progress pwnedlist.ProgressFunc) pwnedlist.ProgressFunc {
+	last := time.Now()
+	return func(records int64) {
+		if progress != nil {
+			progress(records)
+		}
+		if time.Since(last) < checkCheckpointEvery {
+			return
+		}
+		last = time.Now()
+		if err := writeCheckCheckpoint(checkpoint, filename, start+int(records)); err != nil {
+			fmt.Fprintf(os.Stderr, "writing checkpoint: %v\n", err)
+		}
+	}
+}
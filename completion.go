@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+// bashCompletion completes commands and flags by asking pwned itself, with
+// --generate-bash-completion, and everything else as file names.
+const bashCompletion = `_pwned() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    if [[ $COMP_CWORD -gt 1 && $cur != -* ]]; then
+        COMPREPLY=( $(compgen -f -- "$cur") )
+        return 0
+    fi
+    local opts=$("${COMP_WORDS[@]:0:$COMP_CWORD}" --generate-bash-completion 2>/dev/null)
+    COMPREPLY=( $(compgen -W "$opts" -- "$cur") )
+}
+complete -o filenames -F _pwned pwned
+`
+
+// zshCompletion does the same as bashCompletion for zsh.
+const zshCompletion = `#compdef pwned
+_pwned() {
+    if (( CURRENT > 2 )) && [[ $PREFIX != -* ]]; then
+        _files
+        return
+    fi
+    local -a opts
+    opts=(${(f)"$(${words[1,CURRENT-1]} --generate-bash-completion 2>/dev/null)"})
+    compadd -a opts
+}
+compdef _pwned pwned
+`
+
+// completeFlags prints the flags of the command being completed, one per
+// line, for --generate-bash-completion.
+func completeFlags(c *cli.Context) {
+	for _, f := range c.Command.Flags {
+		for _, name := range strings.Split(f.GetName(), ",") {
+			name = strings.TrimSpace(name)
+			if len(name) == 1 {
+				fmt.Fprintf(c.App.Writer, "-%s\n", name)
+			} else {
+				fmt.Fprintf(c.App.Writer, "--%s\n", name)
+			}
+		}
+	}
+}
+
+// writeFishCompletion writes a fish completion script for commands. Fish
+// doesn't run pwned to complete, so all commands and flags are in the script.
+// Arguments are completed as file names, as fish does by default.
+func writeFishCompletion(w io.Writer, commands []cli.Command) {
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c pwned -n __fish_use_subcommand -a %s -d %s\n", cmd.Name, fishQuote(cmd.Usage))
+		for _, f := range cmd.Flags {
+			usage, takesValue := flagUsage(f)
+			fmt.Fprintf(w, "complete -c pwned -n '__fish_seen_subcommand_from %s'", cmd.Name)
+			for _, name := range strings.Split(f.GetName(), ",") {
+				name = strings.TrimSpace(name)
+				if len(name) == 1 {
+					fmt.Fprintf(w, " -s %s", name)
+				} else {
+					fmt.Fprintf(w, " -l %s", name)
+				}
+			}
+			if takesValue {
+				fmt.Fprint(w, " -r")
+			}
+			fmt.Fprintf(w, " -d %s\n", fishQuote(usage))
+		}
+	}
+}
+
+// flagUsage returns the usage of f, and whether it takes a value.
+func flagUsage(f cli.Flag) (string, bool) {
+	switch f := f.(type) {
+	case cli.BoolFlag:
+		return f.Usage, false
+	case cli.BoolTFlag:
+		return f.Usage, false
+	case cli.StringFlag:
+		return f.Usage, true
+	case cli.StringSliceFlag:
+		return f.Usage, true
+	case cli.IntFlag:
+		return f.Usage, true
+	case cli.Int64Flag:
+		return f.Usage, true
+	case cli.Uint64Flag:
+		return f.Usage, true
+	case cli.Float64Flag:
+		return f.Usage, true
+	case cli.DurationFlag:
+		return f.Usage, true
+	}
+	return "", true
+}
+
+// fishQuote quotes s for fish, in single quotes.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// gzipMagic are the first bytes of every gzip file.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isGzip reports whether filename is gzipped, going by its extension or its
+// first bytes.
+func isGzip(filename string) (bool, error) {
+	if filepath.Ext(filename) == ".gz" {
+		return true, nil
+	}
+	return startsWith(filename, gzipMagic)
+}
+
+// startsWith reports whether filename starts with magic.
+func startsWith(filename string, magic []byte) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	b := make([]byte, len(magic))
+	_, err = io.ReadFull(f, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
+	return bytes.Equal(b, magic), err
+}
+
+// isStream reports whether filename can only be read from start to end,
+// because it's stdin or gzipped.
+func isStream(filename string) (bool, error) {
+	if filename == "-" {
+		return true, nil
+	}
+	return isGzip(filename)
+}
+
+type streamReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *streamReader) Close() error {
+	var err error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if cerr := r.closers[i].Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// openStream opens filename, or stdin for "-", decompressing it on the fly if
+// it's gzipped.
+func openStream(filename string) (io.ReadCloser, error) {
+	return openStreamTee(filename, nil)
+}
+
+// openStreamTee is openStream, but also writes everything read from the file,
+// before decompressing it, to w unless it's nil.
+func openStreamTee(filename string, w io.Writer) (io.ReadCloser, error) {
+	var f *os.File
+	if filename == "-" {
+		f = os.Stdin
+	} else {
+		var err error
+		f, err = os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var r io.Reader = f
+	if w != nil {
+		r = io.TeeReader(f, w)
+	}
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(gzipMagic))
+	if filepath.Ext(filename) != ".gz" && !bytes.Equal(magic, gzipMagic) {
+		return &streamReader{br, []io.Closer{f}}, nil
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &streamReader{gz, []io.Closer{f, gz}}, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// convertFile converts the HASH:COUNT list in in to a fixed-width list in out.
+// Either can be "-", for stdin and stdout.
+func convertFile(in, out string, warnOrder bool) error {
+	r, err := openStream(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := createAtomic(out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	var warn func(error)
+	if warnOrder {
+		warn = func(err error) {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+	}
+	n, err := pwnedlist.Convert(w, r, warn)
+	if err == nil {
+		err = w.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "converted %d records\n", n)
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// dedupeFile drops the duplicates from the sorted list in in, writing the
+// result to out. Either can be "-", for stdin and stdout.
+func dedupeFile(in, out string, withCount bool, format pwnedlist.Format) error {
+	r, err := openStream(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := createAtomic(out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	n, dups, err := format.Dedupe(w, r, withCount)
+	if err == nil {
+		err = w.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d records, removed %d duplicates\n", n, dups)
+	return nil
+}
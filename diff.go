@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// diffFiles writes the records of the sorted list in newFile that aren't in
+// the one in oldFile to added, which can be "-" for stdout, and the ones only
+// in oldFile to removed, unless it's empty.
+func diffFiles(oldFile, newFile, added, removed string, withCount bool, format pwnedlist.Format) error {
+	oldList, err := openStream(oldFile)
+	if err != nil {
+		return err
+	}
+	defer oldList.Close()
+	newList, err := openStream(newFile)
+	if err != nil {
+		return err
+	}
+	defer newList.Close()
+	aw, err := createAtomic(added)
+	if err != nil {
+		return err
+	}
+	defer aw.Close()
+	var rw io.Writer
+	var removedList *atomicWriter
+	if removed != "" {
+		if removedList, err = createAtomic(removed); err != nil {
+			return err
+		}
+		defer removedList.Close()
+		rw = removedList
+	}
+	stats, err := format.Diff(aw, rw, oldList, newList, withCount)
+	if err == nil && removedList != nil {
+		err = removedList.Commit()
+	}
+	if err == nil {
+		err = aw.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("%d records added", stats.Added)
+	if withCount {
+		msg += fmt.Sprintf(", %d with a changed count", stats.Changed)
+	}
+	fmt.Fprintf(os.Stderr, "%s, %d removed\n", msg, stats.Removed)
+	return nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// defaultRangeURL is the k-anonymity API of Have I Been Pwned.
+const defaultRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// prefixes is the number of 5 character prefixes, 16^5.
+const prefixes = 1 << 20
+
+// checkpointEvery is the number of prefixes after which the checkpoint file
+// is updated.
+const checkpointEvery = 256
+
+// maxAttempts is the number of times a range is requested before giving up.
+const maxAttempts = 8
+
+type downloader struct {
+	client    *http.Client
+	rangeURL  string
+	format    pwnedlist.Format
+	withCount bool
+	// padding asks the API to pad its responses with hashes that have a
+	// count of 0.
+	padding bool
+}
+
+// fetched is the outcome of requesting a single range.
+type fetched struct {
+	records []byte
+	err     error
+}
+
+// download requests every range from rangeURL and writes the records to out,
+// in order. Until it's complete the list is written under the temporary name
+// of out, and renamed to out at the end. Progress is recorded in checkpoint,
+// and a download is resumed from there if it exists.
+func download(ctx context.Context, d *downloader, out, checkpoint string, concurrency int) error {
+	start, offset, err := readCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	if start == 0 {
+		flags |= os.O_TRUNC
+	}
+	// Unlike with createAtomic, the temporary file is kept on errors, to
+	// resume from.
+	f, err := os.OpenFile(tempName(out), flags, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if start > 0 {
+		// Whatever was written after the checkpoint is fetched again.
+		if err := f.Truncate(offset); err != nil {
+			return err
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "resuming at prefix %05X\n", start)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type job struct {
+		prefix int
+		res    chan fetched
+	}
+	jobs := make(chan job)
+	order := make(chan chan fetched, 4*concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			for j := range jobs {
+				records, err := d.fetch(ctx, j.prefix)
+				j.res <- fetched{records, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		defer close(order)
+		for p := start; p < prefixes; p++ {
+			res := make(chan fetched, 1)
+			select {
+			case order <- res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job{p, res}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	bw := bufio.NewWriterSize(f, 1<<20)
+	progress := isTerminal(os.Stderr)
+	p := start
+	for res := range order {
+		r := <-res
+		if r.err != nil {
+			return fmt.Errorf("prefix %05X: %v", p, r.err)
+		}
+		if _, err := bw.Write(r.records); err != nil {
+			return err
+		}
+		offset += int64(len(r.records))
+		p++
+		if p%checkpointEvery == 0 || p == prefixes {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			if err := writeCheckpoint(checkpoint, p, offset); err != nil {
+				return err
+			}
+			if progress {
+				fmt.Fprintf(os.Stderr, "\r%d/%d prefixes", p, prefixes)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if progress {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tempName(out), out); err != nil {
+		return err
+	}
+	return os.Remove(checkpoint)
+}
+
+// fetch requests the range of prefix, retrying with exponential backoff on
+// errors and on 429 and 5xx responses, and returns it as list records.
+func (d *downloader) fetch(ctx context.Context, prefix int) ([]byte, error) {
+	hexPrefix := fmt.Sprintf("%05X", prefix)
+	url := d.rangeURL + hexPrefix
+	if d.format == pwnedlist.NTLM {
+		url += "?mode=ntlm"
+	}
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		body, retryAfter, err := d.get(ctx, url)
+		if err == nil {
+			return d.records(hexPrefix, body)
+		}
+		if retryAfter < 0 || attempt == maxAttempts {
+			return nil, err
+		}
+		wait := backoff
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// get requests url. On errors worth retrying it returns how long the server
+// asked to wait, or 0; on other errors it returns -1.
+func (d *downloader) get(ctx context.Context, url string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("User-Agent", "pwned")
+	if d.padding {
+		req.Header.Set("Add-Padding", "true")
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, err
+		}
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		var retryAfter time.Duration
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(s) * time.Second
+		}
+		return nil, retryAfter, errors.New(resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, -1, errors.New(resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, 0, nil
+}
+
+// records turns the SUFFIX:COUNT lines of a range response into sorted list
+// records. Suffixes with a count of 0 are padding, and are left out.
+func (d *downloader) records(prefix string, body []byte) ([]byte, error) {
+	var lines []string
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		suffix, count, hasCount := strings.Cut(line, ":")
+		if len(prefix)+len(suffix) != d.format.HashLen || !pwnedlist.IsHex([]byte(strings.ToUpper(suffix))) {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		if !hasCount && d.withCount {
+			return nil, fmt.Errorf("line %q has no count", line)
+		}
+		if hasCount {
+			n, err := strconv.ParseUint(count, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %q: %v", line, err)
+			}
+			if n == 0 {
+				continue
+			}
+		}
+		record := prefix + strings.ToUpper(suffix)
+		if d.withCount {
+			record += ":" + count
+		}
+		lines = append(lines, record)
+	}
+	sort.Strings(lines)
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// readCheckpoint returns the first prefix that still has to be written and
+// the size of the output up to it, or zeros if checkpoint doesn't exist.
+func readCheckpoint(checkpoint string) (int, int64, error) {
+	b, err := os.ReadFile(checkpoint)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	var prefix int
+	var offset int64
+	if _, err := fmt.Sscanf(string(b), "%x %d", &prefix, &offset); err != nil || prefix > prefixes || offset < 0 {
+		return 0, 0, fmt.Errorf("corrupt checkpoint file %q", checkpoint)
+	}
+	return prefix, offset, nil
+}
+
+// writeCheckpoint replaces checkpoint, so it's never half written.
+func writeCheckpoint(checkpoint string, prefix int, offset int64) error {
+	tmp := checkpoint + ".tmp"
+	err := os.WriteFile(tmp, []byte(fmt.Sprintf("%05X %d\n", prefix, offset)), 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, checkpoint)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// hashName describes the hashes of format, like "SHA-1".
+func hashName(format pwnedlist.Format) string {
+	switch format.HashLen {
+	case pwnedlist.SHA1.HashLen:
+		return "SHA-1"
+	case pwnedlist.NTLM.HashLen:
+		return "NTLM"
+	}
+	return fmt.Sprintf("%d character", format.HashLen)
+}
+
+// describeFiles prints how search would read every file, for search
+// --dry-run, and returns an error for the first file that can't be searched.
+// Files that can be seeked are searched with method, like "binary search".
+// With skipBOM, a UTF-8 BOM at the start of a seekable file is reported and
+// left out of its size.
+func describeFiles(w io.Writer, files []string, formats []pwnedlist.Format, withCount, skipBOM bool, method string) error {
+	for i, filename := range files {
+		format := formats[i]
+		ending := "CR + LF"
+		if format.LF {
+			ending = "LF"
+		}
+		kind := fmt.Sprintf("%s hashes, %d byte records ending in %s", hashName(format), format.RecordSize, ending)
+		if withCount {
+			kind = hashName(format) + " HASH:COUNT records"
+		}
+		if filename == "-" {
+			fmt.Fprintf(w, "file \"-\": stdin, %s, linear scan\n", kind)
+			continue
+		}
+		name, how := fmt.Sprintf("file %q", filename), method
+		var r io.ReaderAt
+		var size int64
+		if isURL(filename) {
+			u, err := openURL(context.Background(), filename)
+			if err != nil {
+				return fmt.Errorf("url %q: %v", filename, err)
+			}
+			name, how = fmt.Sprintf("url %q", filename), method+" with range requests"
+			r, size = u, u.Size()
+		} else if zst, err := isZstd(filename); err != nil || zst {
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			z, err := openSeekableZstd(filename)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			defer z.Close()
+			how = fmt.Sprintf("%s over %d seekable zstd frames", method, len(z.compressed)-1)
+			r, size = z, z.Size()
+		} else {
+			fi, err := os.Stat(filename)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			stream, err := isStream(filename)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			if stream {
+				fmt.Fprintf(w, "%s: gzipped, %d bytes, %s, linear scan\n", name, fi.Size(), kind)
+				continue
+			}
+			size = fi.Size()
+			if skipBOM {
+				f, err := os.Open(filename)
+				if err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				}
+				defer f.Close()
+				r = f
+			}
+		}
+		var bom int64
+		if skipBOM {
+			_, size, bom = listAt(r, size, true)
+		}
+		if bom > 0 {
+			kind = fmt.Sprintf("%d byte UTF-8 BOM skipped, %s", bom, kind)
+		}
+		if withCount {
+			fmt.Fprintf(w, "%s: %d bytes, %s, %s\n", name, size, kind, how)
+			continue
+		}
+		if size%int64(format.RecordSize) != 0 {
+			return fmt.Errorf("%s: %d bytes isn't a whole number of %d byte records", name, size, format.RecordSize)
+		}
+		fmt.Fprintf(w, "%s: %d bytes, %d records, %s, %s\n", name, size, format.IndexAt(size), kind, how)
+	}
+	return nil
+}
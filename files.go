@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandArgs returns the list files named by args. Arguments with glob
+// metacharacters are expanded with filepath.Glob, for shells that don't do
+// it, and it's an error for a pattern to match nothing. With recursive set, a
+// directory is replaced by the regular files below it that end in ext, in
+// lexical order. Other arguments are passed on as they are.
+func expandArgs(args []string, recursive bool, ext string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+		}
+		for _, path := range matches {
+			fi, err := os.Stat(path)
+			if !recursive || path == "-" || err != nil || !fi.IsDir() {
+				files = append(files, path)
+				continue
+			}
+			files, err = walkDir(files, path, ext)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// walkDir appends the regular files below dir that end in ext to files. It's
+// an error for dir to have no such files.
+func walkDir(files []string, dir, ext string) ([]string, error) {
+	n := len(files)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(path, ext) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == n {
+		return nil, fmt.Errorf("no files ending in %q found in %q", ext, dir)
+	}
+	return files, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// buildFilter writes a Bloom filter of the list in listFile to filterFile.
+func buildFilter(listFile, filterFile string, withCount bool, p float64, format pwnedlist.Format) error {
+	if listFile == "-" {
+		return errors.New("can't build a filter from stdin, the list is read twice")
+	}
+	records, err := countRecords(listFile, withCount, format)
+	if err != nil {
+		return err
+	}
+	r, err := openStream(listFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	b, err := format.BuildBloomFilter(r, withCount, records, p)
+	if err != nil {
+		return fmt.Errorf("reading %q: %v", listFile, err)
+	}
+	w, err := os.Create(filterFile)
+	if err != nil {
+		return err
+	}
+	n, err := b.WriteTo(w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote a filter of %d bytes for %d records\n", n, records)
+	return nil
+}
+
+// countRecords returns the number of records in listFile. Only fixed-width
+// lists that aren't gzipped can be counted without reading them.
+func countRecords(listFile string, withCount bool, format pwnedlist.Format) (int64, error) {
+	stream, err := isStream(listFile)
+	if err != nil {
+		return 0, err
+	}
+	if !withCount && !stream {
+		fi, err := os.Stat(listFile)
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size() / int64(format.RecordSize), nil
+	}
+	r, err := openStream(listFile)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	s, err := format.Summarize(r, withCount)
+	if err != nil {
+		return 0, fmt.Errorf("reading %q: %v", listFile, err)
+	}
+	return s.Records, nil
+}
+
+// loadFilter reads the Bloom filter in filename, which has to be built for
+// lists of format.
+func loadFilter(filename string, format pwnedlist.Format) (*pwnedlist.BloomFilter, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := pwnedlist.ReadBloomFilter(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading filter %q: %v", filename, err)
+	}
+	if b.HashLen() != format.HashLen {
+		return nil, fmt.Errorf("filter %q is for hashes of %d characters, not %d", filename, b.HashLen(), format.HashLen)
+	}
+	return b, nil
+}
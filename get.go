@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// getRecord returns the hash and count of the record in filename at byte
+// offset, or at the 1-based index if offset is negative.
+func getRecord(filename string, index, offset int64, withCount bool, format pwnedlist.Format) ([]byte, uint64, error) {
+	gzipped, err := isGzip(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	if gzipped {
+		return nil, 0, errors.New("can't read a record of a gzipped list, decompress it first")
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset < 0 {
+		records := format.IndexAt(fi.Size())
+		if index < 1 || index > records {
+			return nil, 0, fmt.Errorf("--index %d is outside the list of %d records", index, records)
+		}
+		offset = format.OffsetOf(index - 1)
+	}
+	return format.RecordAt(f, fi.Size(), offset, withCount)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// buildIndex writes a sparse index of the list in listFile, with the hash of
+// every every-th record, to indexFile.
+func buildIndex(listFile, indexFile string, every int64, format pwnedlist.Format) error {
+	r, err := openStream(listFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	x, err := format.BuildSparseIndex(r, every)
+	if err != nil {
+		return fmt.Errorf("reading %q: %v", listFile, err)
+	}
+	w, err := os.Create(indexFile)
+	if err != nil {
+		return err
+	}
+	n, err := x.WriteTo(w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote an index of %d bytes, with the hash of every %d of %d records\n", n, every, x.Records())
+	return nil
+}
+
+// loadIndex reads the sparse index in filename.
+func loadIndex(filename string) (*pwnedlist.SparseIndex, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	x, err := pwnedlist.ReadSparseIndex(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading index %q: %v", filename, err)
+	}
+	return x, nil
+}
@@ -1,46 +1,429 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math/rand/v2"
+	"net/http"
 	"os"
-	"sort"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/loeyt/pwned/pwnedlist"
 	"github.com/urfave/cli"
+	"golang.org/x/term"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Exit codes of the search and count commands, next to 0 for a found hash.
+const (
+	exitNotFound = 1
+	exitError    = 2
+	// exitBelowThreshold is for search --threshold, when the hash was only
+	// found with a lower count.
+	exitBelowThreshold = 3
 )
 
 func main() {
 	var progress bool
+	var withCount bool
+	var workers int
+	var noOrderCheck bool
+	var checkDuplicates bool
+	var warnOnly bool
+	var bufferSize int
 	var hashString string
+	var password string
+	var noMmap bool
+	var hashesFile string
+	var addr string
+	var in, out string
+	var oldFile, newFile, added, removed string
+	var wordlist string
+	var warnOrder bool
+	var ntlm bool
+	var jsonOutput bool
+	var quiet bool
+	var all bool
+	var interpolation bool
+	var filterFile string
+	var falsePositiveRate float64
+	var recursive bool
+	var ext string
+	var concurrency int
+	var checkpoint string
+	var rangeURL string
+	var online bool
+	var addPadding bool
+	var memory int
+	var tmpDir string
+	var queries int
+	var warm bool
+	var list bool
+	var timeout time.Duration
+	var checksum string
+	var progressInterval time.Duration
+	var maxErrors int
+	var hashLen int
+	var recordSize int
+	var lf, crlf bool
+	var offsetOnly bool
+	var startRecord, limit int
+	var suffix string
+	var output string
+	var jobs int
+	var verbose bool
+	var probeReads bool
+	var reportNearest bool
+	var hashAlgo string
+	var histogram bool
+	var dryRun bool
+	var lowercase bool
+	var cacheSize int
+	var metrics bool
+	var allowFullHash bool
+	var maxConcurrent, queue int
+	var seed int64
+	var presentRatio float64
+	var skipBOM bool
+	var threshold uint64
+	var binaryOutput bool
+	var indexFile string
+	var indexDensity int64
+	var rate bool
 
 	app := cli.NewApp()
 	app.Usage = "A tool to search the Pwned Password list efficiently"
-	app.UsageText = "pwned check <file>...\n   pwned search --hash <SHA-1 hash of password> <file>..."
+	app.UsageText = "pwned check <file>...\n   pwned search --hash <SHA-1 hash of password> <file>...\n   pwned search --password <password> <file>...\n   pwned count --hash <SHA-1 hash of password> <file>...\n   pwned serve [--addr <address>] <file>\n   pwned convert --in <HASH:COUNT file> --out <file>\n   pwned stats <file>...\n   pwned build-filter <list> <filter>\n   pwned build-index <list> <index>\n   pwned download --out <file>\n   pwned merge --out <file> <list>...\n   pwned diff --old <list> --new <list> --added <file>\n   pwned sort --in <file> --out <file>\n   pwned dedupe --in <file> --out <file>\n   pwned bench <list>\n   pwned prefix --hash <prefix> <file>...\n   pwned get --index <n> <file>\n   pwned completion bash|zsh|fish"
 	app.Commands = []cli.Command{
 		{
 			Name:      "check",
 			Usage:     "Checks files to be the correct Pwned Password list format",
-			UsageText: "pwned check [--progress] <file>...",
+			UsageText: "pwned check [--progress [--progress-interval <duration>]] [--with-count] [--workers <n>] [--no-order-check] [--check-duplicates] [--buffer-size <bytes>] [--ntlm] <file>...\n   pwned check --checksum <SHA-256 digest> <file>\n\n   Gzipped files are decompressed on the fly, and checked by a single worker,\n   as are files checked against a digest and checks going past errors with\n   --max-errors. Exits with 1 if any file failed the check.\n\n   --start and --limit check only part of a list of fixed-width records, like\n   the records around a reported error. Records keep their numbers in the\n   errors, and --start seeks, so it can't be used with stdin or gzipped\n   files.\n\n   With --checkpoint the number of records found valid is written to the\n   checkpoint file every 10 seconds, and when the check fails or is\n   interrupted. Running the same check again resumes after them, and the\n   file is removed once the whole list passed. It can't be used with\n   --check-duplicates, which only fails once the whole list was read.\n\n   With --warn-only hashes out of order and duplicates are printed as\n   warnings and counted in the summary, but only malformed records fail the\n   check, for lists that are known to be imperfect.",
 			Flags: []cli.Flag{
 				cli.BoolFlag{
 					Name:        "progress, p",
-					Usage:       "Show progress within the files.",
+					Usage:       "Show progress within the files, if stdout is a terminal.",
 					Destination: &progress,
 				},
+				cli.DurationFlag{
+					Name:        "progress-interval",
+					Usage:       "How often the progress is updated.",
+					Value:       250 * time.Millisecond,
+					Destination: &progressInterval,
+				},
+				cli.BoolFlag{
+					Name:        "rate",
+					Usage:       "Show records and MB per second in the progress, and their average at the end.",
+					Destination: &rate,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Expect HASH:COUNT records, as in the official download.",
+					Destination: &withCount,
+				},
+				cli.IntFlag{
+					Name:        "workers",
+					Usage:       "Number of goroutines checking parts of a file concurrently.",
+					Value:       runtime.NumCPU(),
+					Destination: &workers,
+				},
+				cli.BoolFlag{
+					Name:        "no-order-check",
+					Usage:       "Don't check that the hashes are sorted.",
+					Destination: &noOrderCheck,
+				},
+				cli.BoolFlag{
+					Name:        "check-duplicates",
+					Usage:       "Report hashes that are the same as the one before them.",
+					Destination: &checkDuplicates,
+				},
+				cli.BoolFlag{
+					Name:        "warn-only",
+					Usage:       "Report hashes out of order and duplicates as warnings on stderr, without failing the check.",
+					Destination: &warnOnly,
+				},
+				cli.IntFlag{
+					Name:        "buffer-size",
+					Usage:       "Size of the read buffer in bytes.",
+					Value:       4 << 20,
+					Destination: &bufferSize,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones.",
+					Destination: &ntlm,
+				},
+				cli.IntFlag{
+					Name:        "hash-len",
+					Usage:       "Expect hashes of this many characters, for lists other than SHA-1 and NTLM.",
+					Destination: &hashLen,
+				},
+				cli.IntFlag{
+					Name:        "record-size",
+					Usage:       "Size of a fixed-width record, --hash-len + 2 by default. Bytes between the hash and CR + LF are ignored.",
+					Destination: &recordSize,
+				},
+				cli.BoolFlag{
+					Name:        "lf",
+					Usage:       "Expect fixed-width records to end with just LF. By default the line ending of the first record is used.",
+					Destination: &lf,
+				},
+				cli.BoolFlag{
+					Name:        "crlf",
+					Usage:       "Expect fixed-width records to end with CR + LF, whatever the first record ends with.",
+					Destination: &crlf,
+				},
+				cli.BoolFlag{
+					Name:        "quiet, q",
+					Usage:       "Only report files that failed the check, on stderr.",
+					Destination: &quiet,
+				},
+				cli.BoolFlag{
+					Name:        "json",
+					Usage:       "Print one JSON summary per checked file instead of text.",
+					Destination: &jsonOutput,
+				},
+				cli.BoolFlag{
+					Name:        "recursive, r",
+					Usage:       "Check all files in directories and their subdirectories.",
+					Destination: &recursive,
+				},
+				cli.StringFlag{
+					Name:        "ext",
+					Usage:       "Only check files ending in this extension when walking directories, like .bin.",
+					Destination: &ext,
+				},
+				cli.DurationFlag{
+					Name:        "timeout",
+					Usage:       "Give up after this long, like 10m. Interrupting with Ctrl-C also stops cleanly.",
+					Destination: &timeout,
+				},
+				cli.IntFlag{
+					Name:        "max-errors",
+					Usage:       "Report up to this many bad records per file before stopping, 0 for no limit.",
+					Value:       1,
+					Destination: &maxErrors,
+				},
+				cli.StringFlag{
+					Name:        "checksum",
+					Usage:       "Also check that the file, as stored, has this SHA-256 digest.",
+					Destination: &checksum,
+				},
+				cli.IntFlag{
+					Name:        "start",
+					Usage:       "Skip this many records, and start checking at the next one.",
+					Destination: &startRecord,
+				},
+				cli.IntFlag{
+					Name:        "limit",
+					Usage:       "Stop after checking this many records.",
+					Destination: &limit,
+				},
+				cli.BoolFlag{
+					Name:        "lowercase",
+					Usage:       "Print the first and last hash in lowercase with --json.",
+					Destination: &lowercase,
+				},
+				cli.BoolFlag{
+					Name:        "histogram",
+					Usage:       "Count the hashes by their first character, and print the distribution, which should be about even.",
+					Destination: &histogram,
+				},
+				cli.BoolFlag{
+					Name:        "skip-bom",
+					Usage:       "Skip a UTF-8 BOM at the start of the files, instead of reporting it.",
+					Destination: &skipBOM,
+				},
+				cli.StringFlag{
+					Name:        "checkpoint",
+					Usage:       "Record the progress in this file, and resume from it when it exists.",
+					Destination: &checkpoint,
+				},
 			},
 			Action: func(c *cli.Context) error {
 				if c.NArg() == 0 {
 					cli.ShowCommandHelpAndExit(c, "check", 1)
 				}
-				for _, filename := range c.Args() {
-					fmt.Printf("checking file %q: ", filename)
-					err := checkFile(filename, progress)
-					if err == nil {
-						fmt.Printf("OK\n")
-					} else {
+				if startRecord < 0 || limit < 0 {
+					return cli.NewExitError("--start and --limit can't be negative", 1)
+				}
+				window := startRecord > 0 || limit > 0
+				if window && withCount {
+					return cli.NewExitError("--start and --limit need fixed-width records", 1)
+				}
+				if window && checksum != "" {
+					return cli.NewExitError("--checksum needs the whole file, not --start or --limit", 1)
+				}
+				files, err := expandArgs(c.Args(), recursive, ext)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				format, err := formatFromFlags(ntlm, hashLen, recordSize, lf, crlf, withCount)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				detect := !lf && !crlf && recordSize == 0 && !withCount
+				if checksum != "" {
+					if checksum, err = parseSHA256(checksum); err != nil {
+						return cli.NewExitError("invalid --checksum: "+err.Error(), 1)
+					}
+					if len(files) != 1 {
+						return cli.NewExitError("--checksum needs exactly one file", 1)
+					}
+				}
+				resume := 0
+				if checkpoint != "" {
+					if len(files) != 1 || files[0] == "-" || withCount || window || checksum != "" || maxErrors != 1 {
+						return cli.NewExitError("--checkpoint needs a single file of fixed-width records, without --start, --limit, --checksum or --max-errors", 1)
+					}
+					// Duplicates are only reported once the whole list was
+					// read, so a failed check wouldn't know which records
+					// before the failure are valid.
+					if checkDuplicates {
+						return cli.NewExitError("--checkpoint can't be used with --check-duplicates", 1)
+					}
+					if gzipped, err := isGzip(files[0]); err != nil || gzipped {
+						return cli.NewExitError("--checkpoint needs a file that can be seeked, not a gzipped one", 1)
+					}
+					done, err := readCheckCheckpoint(checkpoint, files[0])
+					if err != nil {
+						return cli.NewExitError(err.Error(), 1)
+					}
+					// The last valid record is checked again, so the order of
+					// the one after it is too.
+					resume = max(done-1, 0)
+					workers = 1
+				}
+				if maxErrors == 0 {
+					maxErrors = -1
+				}
+				ctx, cancel := commandContext(timeout)
+				defer cancel()
+				failed := false
+				for _, filename := range files {
+					if !quiet && !jsonOutput {
+						fmt.Printf("checking file %q: ", filename)
+					}
+					format := format
+					if detect {
+						format = detectLineEnding(filename, format)
+					}
+					start := time.Now()
+					var m *progressMeter
+					var progressFunc pwnedlist.ProgressFunc
+					if progress && !quiet && !jsonOutput && isTerminal(os.Stdout) {
+						m = newProgressMeter(os.Stdout, rate, format.RecordSize)
+						progressFunc = m.update
+					}
+					first := startRecord
+					if checkpoint != "" {
+						first = resume
+						progressFunc = checkpointProgress(checkpoint, filename, first, progressFunc)
+					}
+					var warn func(error)
+					if warnOnly {
+						warn = func(err error) {
+							if !quiet {
+								fmt.Fprintln(os.Stderr, "warning:", err)
+							}
+						}
+					}
+					res, err := checkFile(ctx, filename, pwnedlist.CheckOptions{
+						Progress:         progressFunc,
+						ProgressInterval: progressInterval,
+						WithCount:        withCount,
+						CheckOrder:       !noOrderCheck,
+						CheckDuplicates:  checkDuplicates,
+						Workers:          workers,
+						BufferSize:       bufferSize,
+						Format:           format,
+						MaxErrors:        maxErrors,
+						Start:            first,
+						Limit:            limit,
+						Histogram:        histogram,
+						SkipBOM:          skipBOM,
+						Warn:             warn,
+					}, checksum, m)
+					if m != nil {
+						m.clear()
+					}
+					if checkpoint != "" {
+						// A failed or interrupted check resumes after the
+						// records it found valid, which are the ones before
+						// the failing record.
+						var cerr error
+						if err == nil {
+							cerr = os.Remove(checkpoint)
+						} else {
+							cerr = writeCheckCheckpoint(checkpoint, filename, first+res.Records)
+						}
+						if cerr != nil {
+							fmt.Fprintf(os.Stderr, "checkpoint: %v\n", cerr)
+						}
+					}
+					res.First, res.Last = displayHash(res.First, lowercase), displayHash(res.Last, lowercase)
+					switch {
+					case quiet && err != nil:
+						for _, msg := range res.errors() {
+							fmt.Fprintf(os.Stderr, "checking file %q: %s\n", filename, msg)
+						}
+					case quiet:
+					case jsonOutput:
+						json.NewEncoder(os.Stdout).Encode(res)
+					case err == nil:
+						details := fmt.Sprintf("%d records", res.Records)
+						if first > 0 {
+							details += fmt.Sprintf(" from record %d", first+1)
+						}
+						if format.LF {
+							details += ", LF line endings"
+						}
+						if res.Warnings > 0 {
+							details += fmt.Sprintf(", %d warnings", res.Warnings)
+						}
+						if res.SHA256 != "" {
+							details += ", SHA-256 " + res.SHA256
+						}
+						if rate {
+							size := res.Size
+							if window || first > 0 {
+								size = int64(res.Records) * int64(format.RecordSize)
+							}
+							details += ", " + formatRate(int64(res.Records), size, time.Since(start))
+						}
+						fmt.Printf("OK (%s)\n", details)
+						printHistogram(res.Histogram, res.Records)
+					case len(res.Errors) > 0:
+						fmt.Printf("%d errors\n", len(res.Errors))
+						for _, msg := range res.Errors {
+							fmt.Printf("  %s\n", msg)
+						}
+					default:
 						fmt.Printf("%v\n", err)
 					}
+					if ctx.Err() != nil {
+						return exitWith(exitError)
+					}
+					failed = failed || err != nil
+				}
+				if failed {
+					return exitWith(1)
 				}
 				return nil
 			},
@@ -48,141 +431,2335 @@ func main() {
 		{
 			Name:      "search",
 			Usage:     "Runs a binary search for a hash in the Pwned Password list",
-			UsageText: "pwned search --hash <SHA-1 hash of password> <file>...",
+			UsageText: "pwned search --hash <SHA-1 hash of password> <file>...\n   pwned search --password <password> <file>...\n   pwned search --prompt <file>...\n   pwned search --password-stdin <file>... < password.txt\n   pwned search --hash-bin <file with raw SHA-1 hash> <file>...\n   pwned search --hashes-file <file of SHA-1 hashes> <file>...\n   pwned search --online [--add-padding] --password <password>\n   pwned search --suffix <35 character suffix> <shard file>...\n   pwned search --hash <SHA-1 hash of password> --url https://host/pwned.bin\n\n   With --online no list is needed: the first 5 characters of the hash are\n   sent to the Have I Been Pwned range API, which answers with all hashes\n   starting with them. The password and its full hash never leave the\n   machine, but the API does learn that prefix. --add-padding makes the\n   response size give away less about the prefix.\n\n   With --suffix the files are shards of a single prefix, of records with the\n   hash minus its first 5 characters, like range API responses stored as they\n   are (add --with-count for their counts).\n\n   With --url the list is read from a web server or object store that\n   supports range requests, with a request for every probe, instead of\n   downloading it. Its size comes from a HEAD request.\n\n   With --index-file the first probes are done in an index written by\n   build-index, kept in memory, and only the block of the list the hash would\n   be in is read. That saves most of the reads on a cold disk or with --url.\n   The index has to be rebuilt when the list changes.\n\n   A file of \"-\" reads the list from stdin, and gzipped files are\n   decompressed on the fly. Neither can be seeked, so they are scanned\n   linearly (O(n)) instead of binary searched (O(log n)).\n\n   Zstd files in the seekable format are binary searched, decompressing\n   only the frames the probes land in. The format splits the list into\n   independent frames and adds a table of their sizes, tools like t2sz or\n   seekable_compress from the zstd sources write it, with frames of a few\n   MB. Other zstd files have to be decompressed first.\n\n   With --report-nearest a miss is followed by the records just below and\n   just above where the hash would be, to tell a hash that isn't in the list\n   from one that's mangled or in the wrong format. Either is \"none\" if the\n   hash would be at the start or the end of the list.\n\n   The search stops at the first file with a match, unless --all is given.\n   With more than one --hash, every hash is searched for like that in turn,\n   and the results say which hash they're for. After searching more than one\n   file, a summary of the matches is printed. With --json all results are\n   printed as one array at the end.\n\n   With --jobs the files are searched concurrently, which helps with shards\n   on fast or separate disks. All of them are searched until one fails, and\n   the results are printed in the order of the files, up to the first error,\n   or the first match without --all.\n\n   Exits with 0 if the hash was found, 1 if it wasn't and 2 on errors. With\n   several hashes or --hashes-file it exits with 0 if any of them was found.\n   With --threshold a hash only counts as found if its count is at least\n   that, and the exit code is 3 if hashes were only found with lower counts,\n   so a password policy can reject just the widely breached passwords.",
 			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "hash",
+					Usage: "SHA-1 hash to look for (in hexadecimal notation), can be given more than once",
+				},
 				cli.StringFlag{
-					Name:        "hash",
-					Usage:       "SHA-1 hash to look for (in uppercase hexadecimal notation)",
-					Destination: &hashString,
+					Name:        "password, w",
+					Usage:       "Password to look for (hashed with SHA-1 before searching)",
+					Destination: &password,
+				},
+				cli.StringFlag{
+					Name:  "normalize",
+					Value: "none",
+					Usage: "Unicode normalization of --password before hashing, nfc, nfkc or none (the default, like Have I Been Pwned)",
+				},
+				cli.BoolFlag{
+					Name:  "password-stdin",
+					Usage: "Read the password to look for from stdin, keeping it out of the process list",
+				},
+				cli.BoolFlag{
+					Name:  "prompt",
+					Usage: "Ask for the password to look for on the terminal, without echoing it",
+				},
+				cli.BoolTFlag{
+					Name:  "trim",
+					Usage: "Strip a single trailing newline (LF or CR + LF) from --password-stdin, on by default (--trim=false keeps it)",
+				},
+				cli.StringFlag{
+					Name:  "input-encoding",
+					Usage: "Encoding of --password-stdin, utf8, utf16le or utf16be, unless it starts with a BOM",
+					Value: "utf8",
+				},
+				cli.StringFlag{
+					Name:  "hash-bin",
+					Usage: "File with the hash to look for as raw bytes (20 for SHA-1, 16 for NTLM), \"-\" for stdin",
+				},
+				cli.StringFlag{
+					Name:        "hashes-file",
+					Usage:       "File with one SHA-1 hash per line, all looked up in one pass over the list",
+					Destination: &hashesFile,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Expect HASH:COUNT records, as in the official download",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "no-mmap",
+					Usage:       "Read the file with seeks instead of memory-mapping it",
+					Destination: &noMmap,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Search an NTLM list, hashing --password with NTLM instead of SHA-1",
+					Destination: &ntlm,
+				},
+				cli.IntFlag{
+					Name:        "hash-len",
+					Usage:       "Length of the hashes, for lists other than SHA-1 and NTLM",
+					Destination: &hashLen,
+				},
+				cli.IntFlag{
+					Name:        "record-size",
+					Usage:       "Size of a fixed-width record, --hash-len + 2 by default",
+					Destination: &recordSize,
+				},
+				cli.BoolFlag{
+					Name:        "lf",
+					Usage:       "Search lists whose records end with just LF (by default the first record tells)",
+					Destination: &lf,
+				},
+				cli.BoolFlag{
+					Name:        "crlf",
+					Usage:       "Search lists whose records end with CR + LF, whatever the first record ends with",
+					Destination: &crlf,
+				},
+				cli.BoolFlag{
+					Name:        "json",
+					Usage:       "Print one JSON object per searched file instead of text",
+					Destination: &jsonOutput,
+				},
+				cli.BoolFlag{
+					Name:        "progress, p",
+					Usage:       "Show the probe being examined while searching, if stderr is a terminal",
+					Destination: &progress,
+				},
+				cli.BoolFlag{
+					Name:        "quiet, q",
+					Usage:       "Print nothing but errors, on stderr, and only use the exit code",
+					Destination: &quiet,
+				},
+				cli.BoolFlag{
+					Name:        "all",
+					Usage:       "Search every file, instead of stopping at the first match",
+					Destination: &all,
+				},
+				cli.BoolFlag{
+					Name:        "offset-only",
+					Usage:       "Only print the byte offset of a match, and nothing on a miss (errors go to stderr)",
+					Destination: &offsetOnly,
+				},
+				cli.BoolFlag{
+					Name:        "interpolation",
+					Usage:       "Run an interpolation search instead of a binary search, taking fewer probes (fixed-width lists only)",
+					Destination: &interpolation,
+				},
+				cli.StringFlag{
+					Name:        "filter",
+					Usage:       "Bloom filter written by build-filter, to rule out hashes without reading the files",
+					Destination: &filterFile,
+				},
+				cli.BoolFlag{
+					Name:        "recursive, r",
+					Usage:       "Search all files in directories and their subdirectories",
+					Destination: &recursive,
+				},
+				cli.StringFlag{
+					Name:        "ext",
+					Usage:       "Only search files ending in this extension when walking directories, like .bin",
+					Destination: &ext,
+				},
+				cli.BoolFlag{
+					Name:        "online",
+					Usage:       "Ask the Have I Been Pwned range API instead of searching files",
+					Destination: &online,
+				},
+				cli.BoolFlag{
+					Name:        "add-padding",
+					Usage:       "Have the range API pad its response with random hashes (with --online)",
+					Destination: &addPadding,
+				},
+				cli.StringFlag{
+					Name:        "range-url",
+					Usage:       "URL the 5 character prefix is appended to (with --online)",
+					Value:       defaultRangeURL,
+					Destination: &rangeURL,
+				},
+				cli.DurationFlag{
+					Name:        "timeout",
+					Usage:       "Give up after this long, like 30s (Ctrl-C also stops cleanly)",
+					Destination: &timeout,
+				},
+				cli.StringFlag{
+					Name:        "suffix",
+					Usage:       "Hash without its 5 character prefix to look for, in files of such suffixes (35 characters for SHA-1, 27 for NTLM)",
+					Destination: &suffix,
+				},
+				cli.StringFlag{
+					Name:        "output, o",
+					Usage:       "Write the results to this file instead of stdout",
+					Destination: &output,
+				},
+				cli.BoolFlag{
+					Name:        "verbose, v",
+					Usage:       "Log every probe of the binary search on stderr, with the record and hash it read",
+					Destination: &verbose,
+				},
+				cli.BoolFlag{
+					Name:        "probe-reads-metric",
+					Usage:       "Report how many records the search read to find its answer, with the results",
+					Destination: &probeReads,
+				},
+				cli.BoolFlag{
+					Name:        "report-nearest",
+					Usage:       "On a miss, report the records just below and just above where the hash would be",
+					Destination: &reportNearest,
+				},
+				cli.IntFlag{
+					Name:        "jobs, j",
+					Usage:       "Number of files searched concurrently, can't be used with --progress or --verbose",
+					Value:       1,
+					Destination: &jobs,
+				},
+				cli.StringFlag{
+					Name:        "hash-algo",
+					Usage:       "Hash of the list, sha1 (the default) or ntlm, which --password is hashed with too",
+					Destination: &hashAlgo,
+				},
+				cli.BoolFlag{
+					Name:        "lowercase",
+					Usage:       "Print hashes in lowercase",
+					Destination: &lowercase,
+				},
+				cli.StringSliceFlag{
+					Name:  "url",
+					Usage: "HTTP(S) URL of a list to search with range requests, searched after the files, can be given more than once",
+				},
+				cli.BoolFlag{
+					Name:        "dry-run, n",
+					Usage:       "Check the hashes and files, and print what would be searched and how, without searching",
+					Destination: &dryRun,
+				},
+				cli.BoolFlag{
+					Name:        "skip-bom",
+					Usage:       "Skip a UTF-8 BOM at the start of the files, still counting it in byte offsets",
+					Destination: &skipBOM,
+				},
+				cli.Uint64Flag{
+					Name:        "threshold",
+					Usage:       "Only exit with 0 if a hash was found with at least this count, and with 3 if only lower counts were (with --with-count or --online)",
+					Destination: &threshold,
+				},
+				cli.StringFlag{
+					Name:        "index-file",
+					Usage:       "Sparse index written by build-index, to read the list only where the hash would be (a single fixed-width list)",
+					Destination: &indexFile,
+				},
+				cli.BoolFlag{
+					Name:        "binary-output",
+					Usage:       "Write every found hash as raw bytes (20 for SHA-1, 16 for NTLM) instead of printing results, not to a terminal",
+					Destination: &binaryOutput,
 				},
 			},
-			Action: func(c *cli.Context) error {
-				if c.NArg() == 0 {
-					cli.ShowCommandHelpAndExit(c, "search", 1)
+			Action: func(c *cli.Context) (exitErr error) {
+				ctx, cancel := commandContext(timeout)
+				defer cancel()
+				ntlm, err := ntlmFromFlags(c, ntlm, hashAlgo)
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				if threshold > 0 && !withCount && !online {
+					return cli.NewExitError("--threshold needs counts, from --with-count or --online", exitError)
+				}
+				if binaryOutput {
+					if jsonOutput || offsetOnly || suffix != "" {
+						return cli.NewExitError("--binary-output can't be used together with --json, --offset-only or --suffix", exitError)
+					}
+					if output == "" && isTerminal(os.Stdout) {
+						return cli.NewExitError("--binary-output doesn't write to a terminal, redirect stdout or use --output", exitError)
+					}
+				}
+				if jobs > 1 && (progress || verbose) {
+					return cli.NewExitError("--jobs can't be used with --progress or --verbose, which show the probes of one file at a time", exitError)
+				}
+				if reportNearest && (online || hashesFile != "" || quiet || offsetOnly || binaryOutput) {
+					return cli.NewExitError("--report-nearest adds to the results of searching list files, it can't be used with --online, --hashes-file, --quiet, --offset-only or --binary-output", exitError)
+				}
+				if c.Bool("password-stdin") || c.Bool("prompt") {
+					if c.IsSet("password") || c.Bool("password-stdin") && c.Bool("prompt") {
+						return cli.NewExitError("only one of --password, --password-stdin and --prompt can be used", exitError)
+					}
+					if slices.Contains(c.Args(), "-") || hashesFile == "-" || c.String("hash-bin") == "-" {
+						return cli.NewExitError("the password is read from stdin, so no list or hash can be read from it", exitError)
+					}
+					var pw string
+					if c.Bool("prompt") {
+						pw, err = promptPassword()
+					} else {
+						var r io.Reader
+						if r, err = decodeInput(os.Stdin, c.String("input-encoding")); err != nil {
+							return cli.NewExitError(err.Error(), exitError)
+						}
+						pw, err = readPassword(r, c.BoolT("trim"))
+					}
+					if err != nil {
+						return cli.NewExitError("reading the password: "+err.Error(), exitError)
+					}
+					// From here on it's like the password was given with
+					// --password, without it being on the command line.
+					c.Set("password", pw)
+				}
+				if c.IsSet("trim") && !c.Bool("password-stdin") {
+					return cli.NewExitError("--trim only applies to --password-stdin", exitError)
 				}
-				if hashString == "" {
-					cli.ShowCommandHelpAndExit(c, "search", 1)
+				if c.IsSet("input-encoding") && !c.Bool("password-stdin") {
+					return cli.NewExitError("--input-encoding only applies to --password-stdin", exitError)
 				}
-				for _, filename := range c.Args() {
-					fmt.Printf("searching file %q: ", filename)
-					match, err := searchFile(filename, hashString)
+				w, err := createOutput(output)
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				defer closeOutput(w, &exitErr)
+				if online {
+					if c.NArg() != 0 || len(c.StringSlice("url")) > 0 || hashesFile != "" {
+						return cli.NewExitError("--online doesn't search files, and can't be used with --url or --hashes-file", exitError)
+					}
+					if hashLen != 0 || recordSize != 0 {
+						return cli.NewExitError("--online only knows SHA-1 and NTLM hashes", exitError)
+					}
+					if offsetOnly || suffix != "" {
+						return cli.NewExitError("--offset-only and --suffix need a list file, they can't be used with --online", exitError)
+					}
+					hashes, err := searchHashes(c, password, listFormat(ntlm))
 					if err != nil {
-						fmt.Println("error:", err)
 						return err
 					}
-					if match != -1 {
-						fmt.Printf("hash %d matched! (byte offset %d)\n", match+1, match*42)
+					if dryRun {
+						for _, hashString := range hashes {
+							fmt.Fprintf(w, "would ask %s for the %s hashes starting with %s, to look for %s\n", rangeURL, hashName(listFormat(ntlm)), displayHash(hashString[:5], lowercase), displayHash(hashString, lowercase))
+						}
 						return nil
 					}
-					fmt.Println("no match.")
+					d := &downloader{
+						client:    &http.Client{Timeout: time.Minute},
+						rangeURL:  rangeURL,
+						format:    listFormat(ntlm),
+						withCount: true,
+						padding:   addPadding,
+					}
+					var results []searchResult
+					for _, hashString := range hashes {
+						res := searchOnline(ctx, d, hashString)
+						res.Hash = displayHash(res.Hash, lowercase)
+						res.showHash = len(hashes) > 1
+						switch {
+						case (quiet || binaryOutput) && res.Error != "":
+							fmt.Fprintln(os.Stderr, "error searching online:", res.Error)
+						case quiet:
+						case binaryOutput:
+							if res.Found {
+								err = writeDigest(w, hashString)
+							}
+						case jsonOutput:
+							json.NewEncoder(w).Encode(res)
+						default:
+							res.print(w)
+						}
+						if err != nil {
+							return cli.NewExitError(err.Error(), exitError)
+						}
+						if res.Error != "" {
+							return exitWith(exitError)
+						}
+						results = append(results, res)
+					}
+					return searchExit(results, threshold)
 				}
-				return nil
+				if c.NArg() == 0 && len(c.StringSlice("url")) == 0 {
+					cli.ShowCommandHelpAndExit(c, "search", exitError)
+				}
+				files, err := expandArgs(c.Args(), recursive, ext)
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				for _, url := range c.StringSlice("url") {
+					if !isURL(url) {
+						return cli.NewExitError(fmt.Sprintf("--url %q isn't an http:// or https:// URL", url), exitError)
+					}
+					files = append(files, url)
+				}
+				var format pwnedlist.Format
+				if suffix != "" {
+					if len(c.StringSlice("hash")) > 0 || c.IsSet("password") || c.String("hash-bin") != "" || hashesFile != "" || filterFile != "" {
+						return cli.NewExitError("--suffix can't be used together with --hash, --password, --hash-bin, --hashes-file or --filter", exitError)
+					}
+					if hashLen != 0 || recordSize != 0 {
+						return cli.NewExitError("--suffix can't be used together with --hash-len or --record-size", exitError)
+					}
+					// The records are hashes without their 5 character prefix.
+					format, err = formatFromFlags(false, listFormat(ntlm).HashLen-5, 0, lf, crlf, withCount)
+				} else {
+					format, err = formatFromFlags(ntlm, hashLen, recordSize, lf, crlf, withCount)
+				}
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				if offsetOnly && jsonOutput {
+					return cli.NewExitError("--offset-only and --json can't be used together", exitError)
+				}
+				if binaryOutput && format.HashLen%2 != 0 {
+					return cli.NewExitError(fmt.Sprintf("--binary-output needs hashes of an even length, not %d", format.HashLen), exitError)
+				}
+				if hashesFile != "" {
+					if c.IsSet("hash") || c.IsSet("password") {
+						return cli.NewExitError("--hashes-file can't be used together with --hash or --password", exitError)
+					}
+					if filterFile != "" || offsetOnly {
+						return cli.NewExitError("--filter and --offset-only can't be used together with --hashes-file", exitError)
+					}
+					if len(c.StringSlice("url")) > 0 {
+						return cli.NewExitError("--hashes-file reads every list from start to end, it can't be used with --url", exitError)
+					}
+					if threshold > 0 {
+						return cli.NewExitError("--hashes-file doesn't report counts, it can't be used with --threshold", exitError)
+					}
+					if indexFile != "" {
+						return cli.NewExitError("--hashes-file reads every list from start to end, it can't be used with --index-file", exitError)
+					}
+					if dryRun {
+						queries, err := readHashes(hashesFile, format.HashLen)
+						if err != nil {
+							return cli.NewExitError(err.Error(), exitError)
+						}
+						fmt.Fprintf(w, "would look up %d %s hashes from %q in one pass over each file\n", len(queries), hashName(format), hashesFile)
+						if err := describeFiles(w, files, slices.Repeat([]pwnedlist.Format{format}, len(files)), withCount, skipBOM, "linear scan"); err != nil {
+							return cli.NewExitError(err.Error(), exitError)
+						}
+						return nil
+					}
+					return searchBatch(ctx, w, hashesFile, files, withCount, format, skipBOM, lowercase, binaryOutput)
+				}
+				var hashes []string
+				if suffix != "" {
+					hashString, err := format.ParseHash(suffix)
+					if err != nil {
+						return cli.NewExitError("invalid --suffix: "+err.Error(), exitError)
+					}
+					hashes = []string{hashString}
+				} else if hashes, err = searchHashes(c, password, format); err != nil {
+					return err
+				}
+				var probe pwnedlist.ProbeFunc
+				if progress && !quiet && isTerminal(os.Stderr) {
+					probe = func(n int, offset int64, hash []byte) {
+						fmt.Fprintf(os.Stderr, "\033[u\033[Kprobe %d at byte offset %d ", n, offset)
+					}
+				}
+				var b *pwnedlist.BloomFilter
+				if filterFile != "" {
+					b, err = loadFilter(filterFile, format)
+					if err != nil {
+						return cli.NewExitError(err.Error(), exitError)
+					}
+				}
+				var index *pwnedlist.SparseIndex
+				if indexFile != "" {
+					if len(files) != 1 || withCount || interpolation {
+						return cli.NewExitError("--index-file is for a single list with fixed-width records, without --with-count or --interpolation", exitError)
+					}
+					index, err = loadIndex(indexFile)
+					if err != nil {
+						return cli.NewExitError(err.Error(), exitError)
+					}
+				}
+				fileFormats := make([]pwnedlist.Format, len(files))
+				for i, filename := range files {
+					fileFormats[i] = format
+					if !lf && !crlf && recordSize == 0 && !withCount {
+						fileFormats[i] = detectLineEnding(filename, format)
+					}
+					if fileFormats[i].LF && !lf && !quiet && !offsetOnly {
+						fmt.Fprintf(os.Stderr, "file %q has LF line endings\n", filename)
+					}
+				}
+				if dryRun {
+					for _, hashString := range hashes {
+						fmt.Fprintf(w, "would search for %s hash %s\n", hashName(format), displayHash(hashString, lowercase))
+					}
+					method := "binary search"
+					if interpolation && !withCount {
+						method = "interpolation search"
+					}
+					if err := describeFiles(w, files, fileFormats, withCount, skipBOM, method); err != nil {
+						return cli.NewExitError(err.Error(), exitError)
+					}
+					return nil
+				}
+				var results []searchResult
+				failed := false
+			search:
+				for _, hashString := range hashes {
+					filtered, wroteDigest := false, false
+					if b != nil {
+						filtered = !b.MayContain(hashString)
+					}
+					lookup := func(i int, probe pwnedlist.ProbeFunc) searchResult {
+						filename := files[i]
+						res := searchResult{File: filename, Hash: displayHash(hashString, lowercase), Filtered: filtered, showHash: len(hashes) > 1}
+						var probes int
+						if probeReads {
+							next := probe
+							probe = func(n int, offset int64, hash []byte) {
+								probes++
+								if next != nil {
+									next(n, offset, hash)
+								}
+							}
+						}
+						var err error
+						if filtered {
+							// The filter says the hash isn't in the list.
+						} else if withCount {
+							var offset int64
+							offset, res.Count, err = searchCountFile(ctx, filename, hashString, skipBOM, format, probe)
+							if offset != -1 {
+								res.Found = true
+								res.Offset = &offset
+							}
+						} else {
+							var match, offset int64
+							match, offset, err = searchFile(ctx, filename, hashString, !noMmap, interpolation, skipBOM, index, fileFormats[i], probe)
+							if match != -1 {
+								res.Found = true
+								res.Index = match + 1
+								res.Offset = &offset
+							}
+						}
+						if errors.Is(err, pwnedlist.ErrNotFound) {
+							err = nil
+						}
+						if err == nil && !res.Found && reportNearest {
+							res.nearest = true
+							res.Below, res.Above, err = nearestRecords(ctx, filename, hashString, skipBOM, withCount, fileFormats[i], lowercase)
+						}
+						if err != nil {
+							res.Error = err.Error()
+						}
+						// Linear scans of streams don't probe, so they don't
+						// get a count.
+						if probes > 0 {
+							res.Probes = &probes
+						}
+						return res
+					}
+					var done []searchResult
+					if jobs > 1 {
+						done = searchConcurrently(len(files), jobs, func(i int) searchResult {
+							return lookup(i, nil)
+						})
+					}
+					for i, filename := range files {
+						var res searchResult
+						switch {
+						case done != nil:
+							res = done[i]
+						case verbose:
+							res = lookup(i, traceProbes(fileFormats[i], withCount, lowercase))
+						case probe != nil && !filtered:
+							fmt.Fprint(os.Stderr, "\033[s")
+							res = lookup(i, probe)
+							fmt.Fprint(os.Stderr, "\033[u\033[K")
+						default:
+							res = lookup(i, nil)
+						}
+						results = append(results, res)
+						var err error
+						switch {
+						case (quiet || offsetOnly || binaryOutput) && res.Error != "":
+							fmt.Fprintf(os.Stderr, "error searching file %q: %s\n", filename, res.Error)
+						case quiet, jsonOutput:
+						case offsetOnly:
+							if res.Found {
+								fmt.Fprintln(w, *res.Offset)
+							}
+						case binaryOutput:
+							// With --all a hash is written once, for its first match.
+							if res.Found && !wroteDigest {
+								err = writeDigest(w, hashString)
+								wroteDigest = true
+							}
+						default:
+							res.print(w)
+						}
+						if err != nil {
+							fmt.Fprintln(os.Stderr, "error:", err)
+							failed = true
+							break search
+						}
+						if res.Error != "" {
+							failed = true
+							break search
+						}
+						if res.Found && !all {
+							break
+						}
+					}
+				}
+				switch {
+				case quiet, offsetOnly, binaryOutput:
+				case jsonOutput:
+					json.NewEncoder(w).Encode(results)
+				case len(files) > 1:
+					fmt.Fprintln(w, searchSummary(results, len(files)))
+				}
+				if failed {
+					return exitWith(exitError)
+				}
+				return searchExit(results, threshold)
 			},
 		},
-	}
-	app.Run(os.Args)
-}
-
-func checkFile(filename string, progress bool) error {
-	f, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	var buf [42]byte
-	n, mod := 0, 1
-	if progress {
-		fmt.Print("\033[s")
-	}
-	for {
-		n++
-		_, err = f.Read(buf[:])
-		if err == io.EOF {
-			if !progress {
-				if n > 1000000 {
-					fmt.Printf("%dM ", n/1000000)
-				} else if n > 1000 {
-					fmt.Printf("%dK ", n/1000)
-				} else {
-					fmt.Printf("%d ", n)
+		{
+			Name:      "count",
+			Usage:     "Prints how many times a hash was seen in a HASH:COUNT list",
+			UsageText: "pwned count --hash <SHA-1 hash of password> <file>...\n   pwned count --password <password> <file>...\n\n   Prints just the count. Exits with 1 without printing anything if the hash\n   isn't found, and with 2 on errors. Like search, it reads \"-\" from stdin\n   and decompresses gzipped files on the fly.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "hash",
+					Usage:       "SHA-1 hash to look for (in hexadecimal notation)",
+					Destination: &hashString,
+				},
+				cli.StringFlag{
+					Name:        "password, w",
+					Usage:       "Password to look for (hashed with SHA-1 before searching)",
+					Destination: &password,
+				},
+				cli.StringFlag{
+					Name:  "normalize",
+					Value: "none",
+					Usage: "Unicode normalization of --password before hashing, nfc, nfkc or none (the default, like Have I Been Pwned)",
+				},
+				cli.StringFlag{
+					Name:        "hash-algo",
+					Usage:       "Hash of the list, sha1 (the default) or ntlm, which --password is hashed with too",
+					Destination: &hashAlgo,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() == 0 {
+					cli.ShowCommandHelpAndExit(c, "count", exitError)
 				}
-			}
-			return f.Close()
-		}
-		if err != nil {
-			_ = f.Close()
-			return err
-		}
-		for _, c := range buf[:40] {
-			switch c {
-			case
-				'0', '1', '2', '3', '4', '5', '6', '7',
-				'8', '9', 'A', 'B', 'C', 'D', 'E', 'F':
-			default:
-				_ = f.Close()
-				return fmt.Errorf("hash %d contained characters other than [0-9A-F]", n)
-			}
-		}
-		if buf[40] != '\r' || buf[41] != '\n' {
-			_ = f.Close()
-			return fmt.Errorf("hash %d didn't end with CR + LF", n)
-		}
-		if progress && n%mod == 0 {
-			if n/mod == 1000 {
-				mod *= 1000
-			}
-			m := ' '
-			switch mod {
-			case 1000:
-				m = 'K'
-			case 1000000:
-				m = 'M'
-			}
-			fmt.Printf("\033[u\033[K%d%c ", n/mod, m)
-		}
-	}
-}
-
-func searchFile(filename string, hashString string) (int, error) {
+				files, err := expandArgs(c.Args(), false, "")
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				ntlm, err := ntlmFromFlags(c, false, hashAlgo)
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				format := listFormat(ntlm)
+				hashString, err := hashFromFlags(c, hashString, password, format)
+				if err != nil {
+					return err
+				}
+				for _, filename := range files {
+					var count uint64
+					_, count, err = searchCountFile(context.Background(), filename, hashString, false, format, nil)
+					switch {
+					case errors.Is(err, pwnedlist.ErrNotFound):
+					case err != nil:
+						return cli.NewExitError(fmt.Sprintf("error searching file %q: %v", filename, err), exitError)
+					default:
+						fmt.Println(count)
+						return nil
+					}
+				}
+				return exitWith(exitNotFound)
+			},
+		},
+		{
+			Name:      "scan",
+			Usage:     "Looks up every password of a wordlist in the list",
+			UsageText: "pwned scan [--with-count] [--ntlm] [--input-encoding <encoding>] [--output <file>] --wordlist <file> <file>...\n\n   The wordlist has one plaintext password per line, like a dictionary or a\n   set of generated passwords to audit. They are hashed and sorted, so every\n   list file is read once from start to end, and it can be gzipped or \"-\"\n   for stdin. The passwords that are in the list are printed in the order of\n   the wordlist, with --with-count followed by a tab and their count.\n\n   A wordlist saved as UTF-16, like some Windows tools do, needs\n   --input-encoding utf16le or utf16be, unless it starts with a BOM, which\n   says what it is. Hashing the raw bytes would look up the wrong hashes.\n\n   Exits with 0 if a password was found, 1 if none was and 2 on errors.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "wordlist",
+					Usage:       "File with a password per line, \"-\" for stdin",
+					Destination: &wordlist,
+				},
+				cli.StringFlag{
+					Name:  "input-encoding",
+					Usage: "Encoding of the wordlist, utf8, utf16le or utf16be, unless it starts with a BOM",
+					Value: "utf8",
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Search HASH:COUNT records, and print the count of every password found",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Hash the passwords with NTLM, for the NTLM list",
+					Destination: &ntlm,
+				},
+				cli.StringFlag{
+					Name:        "output, o",
+					Usage:       "Write the passwords found to this file instead of stdout",
+					Destination: &output,
+				},
+			},
+			Action: func(c *cli.Context) (exitErr error) {
+				files := c.Args()
+				if wordlist == "" || len(files) == 0 {
+					cli.ShowCommandHelpAndExit(c, "scan", exitError)
+				}
+				if wordlist == "-" && slices.Contains(files, "-") {
+					return cli.NewExitError("--wordlist and the list can't both be read from stdin", exitError)
+				}
+				ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer cancel()
+				w, err := createOutput(output)
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				defer closeOutput(w, &exitErr)
+				hits, words, err := scanWordlist(ctx, w, wordlist, c.String("input-encoding"), files, withCount, listFormat(ntlm))
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				fmt.Fprintf(os.Stderr, "%d of %d passwords found\n", hits, words)
+				if hits == 0 {
+					return exitWith(exitNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "serve",
+			Usage:     "Serves k-anonymity range queries like the Pwned Passwords API",
+			UsageText: "pwned serve [--addr <address>] [--with-count] [--cache <prefixes>] [--metrics] [--max-concurrent <n>] [--allow-full-hash] [--warm] <file>\n\n   GET /range/<first 5 characters of the hash> returns the remaining 35\n   characters of all hashes in the list starting with them. The list can't\n   be gzipped, as it has to be binary searched.\n\n   With --cache the responses for the most recently requested prefixes are\n   kept in memory. The cache is dropped when the modification time of the\n   list changes.\n\n   Requests with an Add-Padding: true header get responses padded to 1000\n   lines with random suffixes, like the Pwned Passwords API, so the size of\n   an encrypted response doesn't give away the prefix. With --with-count the\n   padding has a count of 0, without it the suffixes are bare like the real\n   ones.\n\n   With --metrics GET /metrics reports the number of requests, their\n   latency, the records per response and the cache hit rate in the\n   Prometheus text format.\n\n   With --allow-full-hash GET /check/<full SHA-1 hash> answers with JSON\n   like {\"found\":true,\"count\":42}, the count being 0 without --with-count.\n   That gives the whole hash away to the server and anyone watching, so it's\n   off by default, and only meant for trusted callers on an internal network.\n\n   With --max-concurrent at most that many requests read the list at the\n   same time, and up to --queue others wait for their turn. Requests beyond\n   that get a 503. Ctrl-C or SIGTERM stops the server once the requests in\n   progress are answered.\n\n   With --warm the list is read from start to end before the server starts\n   listening, which gets it into the page cache much faster than cold\n   requests would. The time this took and how much of the list is resident\n   afterwards are logged.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "addr",
+					Usage:       "Address to listen on",
+					Value:       ":8080",
+					Destination: &addr,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Expect HASH:COUNT records, and include the counts in the responses",
+					Destination: &withCount,
+				},
+				cli.IntFlag{
+					Name:        "cache",
+					Usage:       "Number of prefixes whose responses are cached in memory, 0 for none",
+					Destination: &cacheSize,
+				},
+				cli.BoolFlag{
+					Name:        "metrics",
+					Usage:       "Serve Prometheus metrics on /metrics",
+					Destination: &metrics,
+				},
+				cli.BoolFlag{
+					Name:        "allow-full-hash",
+					Usage:       "Serve /check/<hash> for full hashes, without k-anonymity",
+					Destination: &allowFullHash,
+				},
+				cli.BoolFlag{
+					Name:        "warm",
+					Usage:       "Read the whole list before serving, so the first requests don't wait for the disk",
+					Destination: &warm,
+				},
+				cli.IntFlag{
+					Name:        "max-concurrent",
+					Usage:       "Number of requests reading the list at the same time, 0 for no limit",
+					Destination: &maxConcurrent,
+				},
+				cli.IntFlag{
+					Name:        "queue",
+					Usage:       "Number of requests waiting for a turn with --max-concurrent, before answering 503",
+					Value:       64,
+					Destination: &queue,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					cli.ShowCommandHelpAndExit(c, "serve", 1)
+				}
+				if cacheSize < 0 || maxConcurrent < 0 || queue < 0 {
+					return cli.NewExitError("--cache, --max-concurrent and --queue can't be negative", 1)
+				}
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				err := serve(ctx, addr, c.Args().First(), serveOptions{
+					withCount:     withCount,
+					cacheSize:     cacheSize,
+					metrics:       metrics,
+					maxConcurrent: maxConcurrent,
+					queue:         queue,
+					allowFullHash: allowFullHash,
+					warm:          warm,
+				})
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "convert",
+			Usage:     "Converts a HASH:COUNT list to the fixed-width format search expects",
+			UsageText: "pwned convert [--warn-order] --in <HASH:COUNT file> --out <file>\n\n   Both --in and --out can be \"-\", for stdin and stdout.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "in",
+					Usage:       "List of HASH:COUNT records to convert",
+					Destination: &in,
+				},
+				cli.StringFlag{
+					Name:        "out",
+					Usage:       "File to write the fixed-width records to",
+					Destination: &out,
+				},
+				cli.BoolFlag{
+					Name:        "warn-order",
+					Usage:       "Warn about hashes that are out of order",
+					Destination: &warnOrder,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if in == "" || out == "" || c.NArg() != 0 {
+					cli.ShowCommandHelpAndExit(c, "convert", 1)
+				}
+				err := convertFile(in, out, warnOrder)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "download",
+			Usage:     "Downloads the list from the Have I Been Pwned range API",
+			UsageText: "pwned download [--with-count] [--ntlm] [--concurrency <n>] [--checkpoint <file>] --out <file>\n\n   All 16^5 ranges are requested and written to --out in order. An\n   interrupted download is resumed from the checkpoint file, which is removed\n   once the download completes. Until then the list is written to --out with\n   .tmp appended, so an incomplete list is never mistaken for a whole one.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "out",
+					Usage:       "File to write the list to",
+					Destination: &out,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Write HASH:COUNT records instead of fixed-width ones",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Download the NTLM list instead of the SHA-1 one",
+					Destination: &ntlm,
+				},
+				cli.IntFlag{
+					Name:        "concurrency",
+					Usage:       "Number of ranges requested at the same time",
+					Value:       16,
+					Destination: &concurrency,
+				},
+				cli.StringFlag{
+					Name:        "checkpoint",
+					Usage:       "File recording the progress, --out with .checkpoint appended by default",
+					Destination: &checkpoint,
+				},
+				cli.StringFlag{
+					Name:        "range-url",
+					Usage:       "URL the 5 character prefixes are appended to, for mirrors like pwned serve",
+					Value:       defaultRangeURL,
+					Destination: &rangeURL,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if out == "" || c.NArg() != 0 || concurrency < 1 {
+					cli.ShowCommandHelpAndExit(c, "download", exitError)
+				}
+				if checkpoint == "" {
+					checkpoint = out + ".checkpoint"
+				}
+				d := &downloader{
+					client:    &http.Client{Timeout: time.Minute},
+					rangeURL:  rangeURL,
+					format:    listFormat(ntlm),
+					withCount: withCount,
+				}
+				err := download(context.Background(), d, out, checkpoint, concurrency)
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "build-filter",
+			Usage:     "Writes a Bloom filter of a list, for search --filter",
+			UsageText: "pwned build-filter [--with-count] [--ntlm] [--false-positive-rate <rate>] <list> <filter>\n\n   The filter takes about 1.2 bytes per record at a false positive rate of\n   1%, and 1.8 bytes per record at 0.1%.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Expect HASH:COUNT records, as in the official download",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+				cli.Float64Flag{
+					Name:        "false-positive-rate",
+					Usage:       "Rate at which the filter reports hashes that aren't in the list",
+					Value:       0.01,
+					Destination: &falsePositiveRate,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					cli.ShowCommandHelpAndExit(c, "build-filter", exitError)
+				}
+				err := buildFilter(c.Args()[0], c.Args()[1], withCount, falsePositiveRate, listFormat(ntlm))
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "build-index",
+			Usage:     "Writes a sparse index of a list, for search --index-file",
+			UsageText: "pwned build-index [--ntlm] [--index-density <records>] <list> <index>\n\n   The index has the hash of every --index-density-th record, so a search\n   with it only reads the list in the block of that many records the hash\n   would be in. With the default of 4096 that's 12 probes into the list\n   instead of 30, for an index of about 10 bytes per 1000 records. Lower\n   densities take fewer probes, and a bigger index.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+				cli.Int64Flag{
+					Name:        "index-density",
+					Usage:       "Number of records per hash in the index",
+					Value:       4096,
+					Destination: &indexDensity,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 || indexDensity < 1 {
+					cli.ShowCommandHelpAndExit(c, "build-index", exitError)
+				}
+				format := detectLineEnding(c.Args()[0], listFormat(ntlm))
+				err := buildIndex(c.Args()[0], c.Args()[1], indexDensity, format)
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "merge",
+			Usage:     "Merges sorted lists into one sorted list without duplicates",
+			UsageText: "pwned merge [--with-count] [--ntlm] --out <file> <list>...\n\n   Every list is read once, so memory use doesn't depend on their size.\n   --out can be \"-\" for stdout, and the lists can be gzipped.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "out",
+					Usage:       "File to write the merged list to",
+					Destination: &out,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Merge HASH:COUNT records, keeping the count of the first list with a hash",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if out == "" || c.NArg() == 0 {
+					cli.ShowCommandHelpAndExit(c, "merge", exitError)
+				}
+				files, err := expandArgs(c.Args(), false, "")
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				err = mergeFiles(out, files, withCount, listFormat(ntlm))
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "diff",
+			Usage:     "Writes the records added to a sorted list since an older version of it",
+			UsageText: "pwned diff [--with-count] [--ntlm] [--removed <file>] --old <list> --new <list> --added <file>\n\n   Both lists are read once, side by side, so memory use doesn't depend on\n   their size, and they can be gzipped. With --with-count records whose\n   count changed are written to --added too, with their new count. --added\n   can be \"-\" for stdout.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "old",
+					Usage:       "Older version of the list",
+					Destination: &oldFile,
+				},
+				cli.StringFlag{
+					Name:        "new",
+					Usage:       "Newer version of the list",
+					Destination: &newFile,
+				},
+				cli.StringFlag{
+					Name:        "added",
+					Usage:       "File to write the records only in --new to",
+					Destination: &added,
+				},
+				cli.StringFlag{
+					Name:        "removed",
+					Usage:       "File to write the records only in --old to",
+					Destination: &removed,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Compare HASH:COUNT records, also writing the ones with a changed count to --added",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if oldFile == "" || newFile == "" || added == "" || c.NArg() != 0 {
+					cli.ShowCommandHelpAndExit(c, "diff", exitError)
+				}
+				if oldFile == "-" && newFile == "-" {
+					return cli.NewExitError("--old and --new can't both be read from stdin", exitError)
+				}
+				err := diffFiles(oldFile, newFile, added, removed, withCount, listFormat(ntlm))
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "sort",
+			Usage:     "Sorts a list with fixed-width records, so it can be searched",
+			UsageText: "pwned sort [--ntlm] [--memory <MiB>] [--tmp-dir <dir>] --in <file> --out <file>\n\n   Lists that don't fit in --memory are sorted in chunks, which are written to\n   temporary files and merged. Duplicates are kept, see merge and dedupe to\n   drop them. The sorted list is checked before it replaces --out, unless\n   that's \"-\".",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "in",
+					Usage:       "List to sort, \"-\" for stdin",
+					Destination: &in,
+				},
+				cli.StringFlag{
+					Name:        "out",
+					Usage:       "File to write the sorted list to, \"-\" for stdout",
+					Destination: &out,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+				cli.IntFlag{
+					Name:        "memory",
+					Usage:       "Size of the chunks sorted in memory, in MiB",
+					Value:       512,
+					Destination: &memory,
+				},
+				cli.StringFlag{
+					Name:        "tmp-dir",
+					Usage:       "Directory for the sorted chunks, the system's temporary directory by default",
+					Destination: &tmpDir,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if in == "" || out == "" || c.NArg() != 0 || memory < 1 {
+					cli.ShowCommandHelpAndExit(c, "sort", exitError)
+				}
+				err := sortFile(in, out, memory<<20, tmpDir, listFormat(ntlm))
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "dedupe",
+			Usage:     "Drops duplicate hashes from a sorted list",
+			UsageText: "pwned dedupe [--with-count] [--ntlm] --in <file> --out <file>\n\n   The list is streamed, so memory use doesn't depend on its size. Both --in\n   and --out can be \"-\", for stdin and stdout.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "in",
+					Usage:       "Sorted list to drop the duplicates from",
+					Destination: &in,
+				},
+				cli.StringFlag{
+					Name:        "out",
+					Usage:       "File to write the list without duplicates to",
+					Destination: &out,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Expect HASH:COUNT records, adding up the counts of duplicates",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if in == "" || out == "" || c.NArg() != 0 {
+					cli.ShowCommandHelpAndExit(c, "dedupe", exitError)
+				}
+				err := dedupeFile(in, out, withCount, listFormat(ntlm))
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "bench",
+			Usage:     "Measures the latency of searches in a list",
+			UsageText: "pwned bench [--queries <n>] [--warm] [--no-mmap] [--interpolation] [--filter <file>] [--ntlm] [--seed <n>] [--present-ratio <fraction>] <list>\n\n   Half of the queries are hashes from random records of the list, the other\n   half are random, or --present-ratio of them are from the list. The seed of\n   the random queries is printed, and runs with the same --seed search for\n   the same hashes, so they can be compared.\n   Every query runs a search like pwned search does, including opening the\n   list. Without --warm the list is only cold if it isn't cached already.",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:        "queries",
+					Usage:       "Number of hashes to search for",
+					Value:       10000,
+					Destination: &queries,
+				},
+				cli.BoolFlag{
+					Name:        "warm",
+					Usage:       "Read the whole list before searching, so it's in the page cache",
+					Destination: &warm,
+				},
+				cli.BoolFlag{
+					Name:        "no-mmap",
+					Usage:       "Read the file with seeks instead of memory-mapping it",
+					Destination: &noMmap,
+				},
+				cli.BoolFlag{
+					Name:        "interpolation",
+					Usage:       "Run interpolation searches instead of binary searches",
+					Destination: &interpolation,
+				},
+				cli.StringFlag{
+					Name:        "filter",
+					Usage:       "Bloom filter written by build-filter, checked before every search",
+					Destination: &filterFile,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+				cli.Int64Flag{
+					Name:        "seed",
+					Usage:       "Seed of the random queries, a random one by default",
+					Destination: &seed,
+				},
+				cli.Float64Flag{
+					Name:        "present-ratio",
+					Usage:       "Fraction of the queries taken from the list, between 0 and 1",
+					Value:       0.5,
+					Destination: &presentRatio,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 || queries < 1 {
+					cli.ShowCommandHelpAndExit(c, "bench", exitError)
+				}
+				if presentRatio < 0 || presentRatio > 1 {
+					return cli.NewExitError("--present-ratio has to be between 0 and 1", exitError)
+				}
+				if !c.IsSet("seed") {
+					seed = rand.Int64()
+				}
+				opts := benchOptions{
+					queries:       queries,
+					warm:          warm,
+					useMmap:       !noMmap,
+					interpolation: interpolation,
+					format:        listFormat(ntlm),
+					seed:          seed,
+					presentRatio:  presentRatio,
+				}
+				if filterFile != "" {
+					var err error
+					opts.filter, err = loadFilter(filterFile, opts.format)
+					if err != nil {
+						return cli.NewExitError(err.Error(), exitError)
+					}
+				}
+				if err := bench(c.Args()[0], opts); err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "prefix",
+			Usage:     "Counts the hashes starting with a prefix",
+			UsageText: "pwned prefix [--list] [--with-count] [--ntlm] --hash <prefix> <file>...\n\n   The prefix can be any part of the start of a hash, in hexadecimal notation.\n   Exits with 0 if any hash matched, 1 if none did and 2 on errors.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "hash",
+					Usage:       "Start of the hashes to count",
+					Destination: &hashString,
+				},
+				cli.BoolFlag{
+					Name:        "list",
+					Usage:       "Print the matching hashes before the count",
+					Destination: &list,
+				},
+				cli.BoolFlag{
+					Name:        "lowercase",
+					Usage:       "Print hashes in lowercase",
+					Destination: &lowercase,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Expect HASH:COUNT records, as in the official download",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if hashString == "" || c.NArg() == 0 {
+					cli.ShowCommandHelpAndExit(c, "prefix", exitError)
+				}
+				format := listFormat(ntlm)
+				prefix := strings.ToUpper(hashString)
+				if len(prefix) > format.HashLen || !pwnedlist.IsHex([]byte(prefix)) {
+					return cli.NewExitError(fmt.Sprintf("invalid --hash: %q isn't a prefix of up to %d hexadecimal characters", prefix, format.HashLen), exitError)
+				}
+				files, err := expandArgs(c.Args(), false, "")
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				matches := 0
+				for _, filename := range files {
+					err := prefixFile(filename, prefix, withCount, format, func(hash []byte, count uint64) error {
+						matches++
+						switch {
+						case list && withCount:
+							fmt.Printf("%s:%d\n", displayHash(string(hash), lowercase), count)
+						case list:
+							fmt.Printf("%s\n", displayHash(string(hash), lowercase))
+						}
+						return nil
+					})
+					if err != nil {
+						return cli.NewExitError(fmt.Sprintf("error searching file %q: %v", filename, err), exitError)
+					}
+				}
+				fmt.Println(matches)
+				if matches == 0 {
+					return exitWith(exitNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "Prints a single record of a list by its position",
+			UsageText: "pwned get [--with-count] [--ntlm] --index <n> <file>\n   pwned get [--with-count] [--ntlm] --offset <byte offset> <file>\n\n   The index counts records from 1 and the offset is in bytes, like the ones\n   search --json reports, so the records around a match, or around the\n   hashes prefix --list prints, can be looked at. Lists\n   with counts don't have fixed-width records, so they only take --offset.\n   Prints the hash, and its count for lists with counts.",
+			Flags: []cli.Flag{
+				cli.Int64Flag{
+					Name:  "index",
+					Usage: "1-based number of the record to print",
+				},
+				cli.Int64Flag{
+					Name:  "offset",
+					Usage: "Byte offset of the record to print",
+				},
+				cli.BoolFlag{
+					Name:        "lowercase",
+					Usage:       "Print hashes in lowercase",
+					Destination: &lowercase,
+				},
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Expect HASH:COUNT records, as in the official download",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 || c.IsSet("index") == c.IsSet("offset") {
+					cli.ShowCommandHelpAndExit(c, "get", exitError)
+				}
+				index, offset := c.Int64("index"), int64(-1)
+				if c.IsSet("offset") {
+					if offset = c.Int64("offset"); offset < 0 {
+						return cli.NewExitError("--offset can't be negative", exitError)
+					}
+				} else if withCount {
+					return cli.NewExitError("--index needs fixed-width records, use --offset for lists with counts", exitError)
+				}
+				filename := c.Args().First()
+				format := listFormat(ntlm)
+				if !withCount {
+					format = detectLineEnding(filename, format)
+				}
+				hash, count, err := getRecord(filename, index, offset, withCount, format)
+				if err != nil {
+					return cli.NewExitError(fmt.Sprintf("error reading file %q: %v", filename, err), exitError)
+				}
+				if withCount {
+					fmt.Printf("%s:%d\n", displayHash(string(hash), lowercase), count)
+				} else {
+					fmt.Printf("%s\n", displayHash(string(hash), lowercase))
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "stats",
+			Usage:     "Summarizes list files",
+			UsageText: "pwned stats [--with-count] [--ntlm] [--json] <file>...\n\n   Every file is read from start to end, a file of \"-\" reads stdin.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:        "with-count",
+					Usage:       "Expect HASH:COUNT records, and sum up their counts",
+					Destination: &withCount,
+				},
+				cli.BoolFlag{
+					Name:        "ntlm",
+					Usage:       "Expect 32 character NTLM hashes instead of SHA-1 ones",
+					Destination: &ntlm,
+				},
+				cli.BoolFlag{
+					Name:        "json",
+					Usage:       "Print one JSON object per file instead of a table",
+					Destination: &jsonOutput,
+				},
+				cli.BoolFlag{
+					Name:        "lowercase",
+					Usage:       "Print hashes in lowercase",
+					Destination: &lowercase,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() == 0 {
+					cli.ShowCommandHelpAndExit(c, "stats", exitError)
+				}
+				files, err := expandArgs(c.Args(), false, "")
+				if err != nil {
+					return cli.NewExitError(err.Error(), exitError)
+				}
+				for i, filename := range files {
+					res, err := statsFile(filename, withCount, listFormat(ntlm))
+					if err != nil {
+						return cli.NewExitError(fmt.Sprintf("error reading file %q: %v", filename, err), exitError)
+					}
+					res.First, res.Last = displayHash(res.First, lowercase), displayHash(res.Last, lowercase)
+					if jsonOutput {
+						json.NewEncoder(os.Stdout).Encode(res)
+						continue
+					}
+					if i > 0 {
+						fmt.Println()
+					}
+					res.print()
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "verify",
+			Usage:     "Checks the SHA-256 digest of a list file",
+			UsageText: "pwned verify [--sha256 <expected digest>] <file>\n\n   The digest is of the file as stored, so gzipped files aren't decompressed,\n   and a file of \"-\" reads stdin. It's printed like sha256sum does, also\n   when it matches, so it can be recorded. Exits with 0 if it matches, 1 if\n   it doesn't and 2 on errors.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "sha256",
+					Usage:       "Digest the file should have, in hexadecimal notation",
+					Destination: &checksum,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					cli.ShowCommandHelpAndExit(c, "verify", exitError)
+				}
+				filename := c.Args().First()
+				var expected string
+				if checksum != "" {
+					var err error
+					if expected, err = parseSHA256(checksum); err != nil {
+						return cli.NewExitError("invalid --sha256: "+err.Error(), exitError)
+					}
+				}
+				digest, err := sha256File(filename)
+				if err != nil {
+					return cli.NewExitError(fmt.Sprintf("error reading file %q: %v", filename, err), exitError)
+				}
+				fmt.Printf("%s  %s\n", digest, filename)
+				if expected != "" && digest != expected {
+					return cli.NewExitError(fmt.Sprintf("file %q: %v", filename, &checksumError{digest, expected}), exitNotFound)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "completion",
+			Usage:     "Prints a shell completion script",
+			UsageText: "pwned completion bash|zsh|fish\n\n   Completes commands, flags and file names. To use it, source the output in\n   the shell's startup file, like this in ~/.bashrc:\n\n      source <(pwned completion bash)\n\n   The bash and zsh scripts ask pwned for the commands and flags, the fish\n   script lists the ones of this version of pwned.",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					cli.ShowCommandHelpAndExit(c, "completion", 1)
+				}
+				switch shell := c.Args().First(); shell {
+				case "bash":
+					fmt.Print(bashCompletion)
+				case "zsh":
+					fmt.Print(zshCompletion)
+				case "fish":
+					writeFishCompletion(os.Stdout, app.Commands)
+				default:
+					return cli.NewExitError(fmt.Sprintf("unknown shell %q, expected bash, zsh or fish", shell), 1)
+				}
+				return nil
+			},
+		},
+	}
+	app.EnableBashCompletion = true
+	for i := range app.Commands {
+		app.Commands[i].BashComplete = completeFlags
+	}
+	app.Run(os.Args)
+}
+
+// commandContext returns a context that's cancelled on SIGINT, and after
+// timeout unless it's 0.
+func commandContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if timeout == 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// formatFromFlags returns the list format given by --ntlm, or by --hash-len
+// and --record-size if either is set, with the line ending given by --lf.
+func formatFromFlags(ntlm bool, hashLen, recordSize int, lf, crlf, withCount bool) (pwnedlist.Format, error) {
+	if lf && crlf {
+		return pwnedlist.Format{}, errors.New("--lf and --crlf can't be used together")
+	}
+	if lf && withCount {
+		return pwnedlist.Format{}, errors.New("--lf only works for fixed-width records, not with --with-count")
+	}
+	format := listFormat(ntlm)
+	if hashLen != 0 || recordSize != 0 {
+		if ntlm {
+			return pwnedlist.Format{}, errors.New("--ntlm can't be used together with --hash-len or --record-size")
+		}
+		format = pwnedlist.Format{HashLen: hashLen, RecordSize: recordSize}
+		if format.HashLen == 0 {
+			format.HashLen = pwnedlist.SHA1.HashLen
+		}
+	}
+	format.LF = lf
+	if recordSize == 0 {
+		format.RecordSize = format.HashLen + len(format.LineEnding())
+	}
+	return format, format.Validate()
+}
+
+// detectLineEnding returns format with the line ending of the first record
+// in filename, or at a URL, after a UTF-8 BOM if it has one. It's left as it
+// is for stdin, and for files that can't be read or are too short to tell.
+func detectLineEnding(filename string, format pwnedlist.Format) pwnedlist.Format {
+	if filename == "-" {
+		return format
+	}
+	var r io.Reader
+	if isURL(filename) {
+		ra, err := openURL(context.Background(), filename)
+		if err != nil {
+			return format
+		}
+		r = io.NewSectionReader(ra, 0, ra.Size())
+	} else if zst, _ := isZstd(filename); zst {
+		z, err := openSeekableZstd(filename)
+		if err != nil {
+			return format
+		}
+		defer z.Close()
+		r = io.NewSectionReader(z, 0, z.Size())
+	} else {
+		stream, err := openStream(filename)
+		if err != nil {
+			return format
+		}
+		defer stream.Close()
+		r = stream
+	}
+	// A UTF-8 BOM is skipped, for --skip-bom.
+	r, _ = skipStreamBOM(r, true)
+	buf := make([]byte, format.HashLen+1)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return format
+	}
+	if buf[format.HashLen] == '\n' {
+		format.LF = true
+		format.RecordSize = format.HashLen + 1
+	} else {
+		format.LF = false
+		format.RecordSize = format.HashLen + 2
+	}
+	return format
+}
+
+// exitWith makes the cli package exit with code, without printing anything.
+func exitWith(code int) error {
+	return cli.NewExitError("", code)
+}
+
+// ntlmFromFlags returns whether NTLM hashes are asked for, with --ntlm or
+// --hash-algo ntlm. Other lists than SHA-1 and NTLM ones are given with
+// --hash-len, and have no hash algorithm.
+func ntlmFromFlags(c *cli.Context, ntlm bool, hashAlgo string) (bool, error) {
+	if hashAlgo == "" {
+		return ntlm, nil
+	}
+	if c.Int("hash-len") != 0 || c.Int("record-size") != 0 {
+		return false, errors.New("--hash-algo can't be used together with --hash-len or --record-size")
+	}
+	switch hashAlgo {
+	case "sha1":
+		if ntlm {
+			return false, errors.New("--ntlm can't be used together with --hash-algo sha1")
+		}
+		return false, nil
+	case "ntlm":
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown --hash-algo %q, expected sha1 or ntlm", hashAlgo)
+}
+
+// displayHash returns hash as it's printed, in lowercase if lowercase is
+// set. Lists and lookups always use uppercase.
+func displayHash(hash string, lowercase bool) string {
+	if lowercase {
+		return strings.ToLower(hash)
+	}
+	return hash
+}
+
+func listFormat(ntlm bool) pwnedlist.Format {
+	if ntlm {
+		return pwnedlist.NTLM
+	}
+	return pwnedlist.SHA1
+}
+
+// searchHashes returns the hashes the search command looks for: all --hash
+// flags if there's more than one, or else the one given by hashFromFlags.
+func searchHashes(c *cli.Context, password string, format pwnedlist.Format) ([]string, error) {
+	flags := c.StringSlice("hash")
+	if len(flags) <= 1 {
+		hashString := ""
+		if len(flags) == 1 {
+			hashString = flags[0]
+		}
+		hashString, err := hashFromFlags(c, hashString, password, format)
+		if err != nil {
+			return nil, err
+		}
+		return []string{hashString}, nil
+	}
+	if c.IsSet("password") || c.String("hash-bin") != "" {
+		return nil, cli.NewExitError("more than one --hash can't be used together with --password or --hash-bin", exitError)
+	}
+	hashes := make([]string, len(flags))
+	for i, flag := range flags {
+		hashString, err := format.ParseHash(flag)
+		if err != nil {
+			return nil, cli.NewExitError("invalid --hash: "+err.Error(), exitError)
+		}
+		hashes[i] = hashString
+	}
+	return hashes, nil
+}
+
+// hashFromFlags returns the hash to search for, either given directly, read
+// from a binary file or computed from a password.
+func hashFromFlags(c *cli.Context, hashString, password string, format pwnedlist.Format) (string, error) {
+	if hashString != "" && c.IsSet("password") {
+		return "", cli.NewExitError("--hash and --password can't be used together", exitError)
+	}
+	if hashBin := c.String("hash-bin"); hashBin != "" {
+		if hashString != "" || c.IsSet("password") {
+			return "", cli.NewExitError("--hash-bin can't be used together with --hash or --password", exitError)
+		}
+		hash, err := readBinaryHash(hashBin, format)
+		if err != nil {
+			return "", cli.NewExitError("invalid --hash-bin: "+err.Error(), exitError)
+		}
+		return hash, nil
+	}
+	if c.IsSet("password") {
+		var err error
+		if password, err = normalizePassword(password, c.String("normalize")); err != nil {
+			return "", cli.NewExitError(err.Error(), exitError)
+		}
+	} else if c.IsSet("normalize") {
+		return "", cli.NewExitError("--normalize only applies to --password", exitError)
+	}
+	if c.IsSet("password") && format == pwnedlist.NTLM {
+		return pwnedlist.HashPasswordNTLM(password), nil
+	}
+	if c.IsSet("password") && format.HashLen != pwnedlist.SHA1.HashLen {
+		return "", cli.NewExitError("--password only works for SHA-1 and NTLM lists", exitError)
+	}
+	if c.IsSet("password") {
+		return pwnedlist.HashPassword(password), nil
+	}
+	if hashString == "" {
+		cli.ShowCommandHelpAndExit(c, c.Command.Name, exitError)
+	}
+	hashString, err := format.ParseHash(hashString)
+	if err != nil {
+		return "", cli.NewExitError("invalid --hash: "+err.Error(), exitError)
+	}
+	return hashString, nil
+}
+
+// normalizePassword returns password in the Unicode normalization form of
+// --normalize. Have I Been Pwned hashes passwords as they were submitted, so
+// "none" keeps them as they are, but a site normalizing passwords at signup
+// has to search for them normalized the same way.
+func normalizePassword(password, form string) (string, error) {
+	switch form {
+	case "none":
+		return password, nil
+	case "nfc":
+		return norm.NFC.String(password), nil
+	case "nfkc":
+		return norm.NFKC.String(password), nil
+	}
+	return "", fmt.Errorf("invalid --normalize %q, it has to be nfc, nfkc or none", form)
+}
+
+// checkResult is the summary of checking a single file, as printed by check
+// --json.
+type checkResult struct {
+	File       string `json:"file"`
+	Size       int64  `json:"size"`
+	Records    int    `json:"records"`
+	First      string `json:"first,omitempty"`
+	Last       string `json:"last,omitempty"`
+	LineEnding string `json:"line_ending,omitempty"`
+	// Histogram has the number of hashes starting with 0 up to F.
+	Histogram         []int `json:"histogram,omitempty"`
+	OrderChecked      bool  `json:"order_checked"`
+	Ordered           bool  `json:"ordered"`
+	DuplicatesChecked bool  `json:"duplicates_checked"`
+	Duplicates        int   `json:"duplicates"`
+	// Warnings is the number of hashes out of order or duplicate, with
+	// --warn-only.
+	Warnings int    `json:"warnings,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+	// Errors lists every error if the check went past more than one.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// errors returns the messages of all errors found.
+func (res checkResult) errors() []string {
+	if len(res.Errors) > 0 {
+		return res.Errors
+	}
+	return []string{res.Error}
+}
+
+// checkFile checks filename, and with checksum set that its SHA-256 digest is
+// checksum, in the same pass.
+func checkFile(ctx context.Context, filename string, opts pwnedlist.CheckOptions, checksum string, m *progressMeter) (checkResult, error) {
+	res := checkResult{
+		File:              filename,
+		OrderChecked:      opts.CheckOrder,
+		DuplicatesChecked: opts.CheckDuplicates,
+	}
+	if fi, err := os.Stat(filename); err == nil {
+		res.Size = fi.Size()
+	}
+	if !opts.WithCount {
+		res.LineEnding = "CR+LF"
+		if opts.Format.LF {
+			res.LineEnding = "LF"
+		}
+	}
+	var digest hash.Hash
+	if checksum != "" {
+		digest = sha256.New()
+	}
+	sum, err := checkList(ctx, filename, opts, digest, m)
+	if err == nil && digest != nil {
+		res.SHA256 = hex.EncodeToString(digest.Sum(nil))
+		if res.SHA256 != checksum {
+			err = &checksumError{res.SHA256, checksum}
+		}
+	}
+	res.Records, res.First, res.Last, res.Duplicates = sum.Records, sum.First, sum.Last, sum.Duplicates
+	res.Histogram = sum.Histogram
+	var orderErr *pwnedlist.OrderError
+	res.Ordered = opts.CheckOrder && !errors.As(err, &orderErr) && sum.OutOfOrder == 0
+	if opts.Warn != nil {
+		res.Warnings = sum.OutOfOrder + sum.Duplicates
+	}
+	res.Valid = err == nil
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			res.Errors = append(res.Errors, err.Error())
+		}
+	}
+	return res, err
+}
+
+// printHistogram prints the share of the records that each first hash
+// character has, if there's a histogram.
+func printHistogram(histogram []int, records int) {
+	if histogram == nil || records == 0 {
+		return
+	}
+	for c, n := range histogram {
+		fmt.Printf("  %X  %9d  %5.2f%%\n", c, n, 100*float64(n)/float64(records))
+	}
+}
+
+// checkList checks the list in filename. Unless digest is nil, the file is
+// read by a single worker, and all of it is written to digest too. If m isn't
+// nil, it counts the bytes that a single worker reads.
+func checkList(ctx context.Context, filename string, opts pwnedlist.CheckOptions, digest hash.Hash, m *progressMeter) (pwnedlist.CheckSummary, error) {
+	var sum pwnedlist.CheckSummary
+	// Stdin is streamed like a gzipped file, openStreamTee tells them apart.
+	stdin := filename == "-"
+	if stdin && opts.Start > 0 {
+		return sum, errors.New("can't seek to --start in stdin")
+	}
+	gzipped := false
+	if !stdin {
+		var err error
+		if gzipped, err = isGzip(filename); err != nil {
+			return sum, err
+		}
+	}
+	if gzipped && opts.Start > 0 {
+		return sum, errors.New("can't seek to --start in a gzipped file")
+	}
+	if stdin || gzipped || digest != nil {
+		var w io.Writer
+		if digest != nil {
+			w = digest
+		}
+		r, err := openStreamTee(filename, w)
+		if err != nil {
+			return sum, err
+		}
+		var cr io.Reader = r
+		if m != nil {
+			cr = m.reader(r)
+		}
+		sum, err = pwnedlist.CheckContext(ctx, cr, opts)
+		if err == nil && digest != nil {
+			// Whatever the check didn't need still counts for the digest.
+			_, err = io.Copy(io.Discard, r)
+		}
+		if err != nil {
+			_ = r.Close()
+			return sum, err
+		}
+		return sum, r.Close()
+	}
 	f, err := os.Open(filename)
 	if err != nil {
-		return -1, err
+		return sum, err
+	}
+	if opts.Start > 0 {
+		if err := seekRecord(f, opts.Start, opts.Format, opts.SkipBOM); err != nil {
+			_ = f.Close()
+			return sum, err
+		}
+	}
+	window := opts.Start > 0 || opts.Limit > 0
+	if opts.Workers > 1 && !opts.WithCount && !window && (opts.MaxErrors == 0 || opts.MaxErrors == 1) {
+		var fi os.FileInfo
+		fi, err = f.Stat()
+		if err == nil {
+			sum, err = pwnedlist.CheckParallelContext(ctx, f, fi.Size(), opts)
+		}
+	} else {
+		var r io.Reader = f
+		if m != nil {
+			r = m.reader(f)
+		}
+		sum, err = pwnedlist.CheckContext(ctx, r, opts)
+	}
+	if err != nil {
+		_ = f.Close()
+		return sum, err
 	}
+	return sum, f.Close()
+}
+
+// seekRecord seeks f to the record after the first start ones, which come
+// after a UTF-8 BOM if skipBOM is set and f starts with one. It's an error
+// for the list to have fewer records.
+func seekRecord(f *os.File, start int, format pwnedlist.Format, skipBOM bool) error {
 	fi, err := f.Stat()
 	if err != nil {
-		return -1, err
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return errors.New("can't seek to --start in a file that isn't a regular file")
+	}
+	_, size, bom := listAt(f, fi.Size(), skipBOM)
+	offset := format.OffsetOf(int64(start))
+	if offset >= size {
+		return fmt.Errorf("--start %d is past the end of the list of %d records", start, format.IndexAt(size))
+	}
+	_, err = f.Seek(bom+offset, io.SeekStart)
+	return err
+}
+
+// writeDigest writes hash, in hexadecimal notation, to w as raw bytes, for
+// search --binary-output.
+func writeDigest(w io.Writer, hash string) error {
+	b, err := hex.DecodeString(hash)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// searchExit returns the exit status of a search with results: 0 if a hash
+// was found with a count of at least threshold, exitBelowThreshold if hashes
+// were only found with lower counts, and exitNotFound if none was found.
+func searchExit(results []searchResult, threshold uint64) error {
+	below := false
+	for _, res := range results {
+		if !res.Found {
+			continue
+		}
+		if res.Count >= threshold {
+			return nil
+		}
+		below = true
+	}
+	if below {
+		return exitWith(exitBelowThreshold)
+	}
+	return exitWith(exitNotFound)
+}
+
+// searchResult is the outcome of searching a single file, as printed by the
+// search command.
+type searchResult struct {
+	File string `json:"file,omitempty"`
+	// Online is set if the range API was asked instead of a file.
+	Online bool   `json:"online,omitempty"`
+	Hash   string `json:"hash"`
+	Found  bool   `json:"found"`
+	// Index is the 1-based record number, only known for fixed-width lists.
+	Index  int64  `json:"index,omitempty"`
+	Offset *int64 `json:"offset,omitempty"`
+	Count  uint64 `json:"count,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Filtered is set if the hash was ruled out by a Bloom filter, without
+	// reading the file.
+	Filtered bool `json:"filtered,omitempty"`
+	// Probes is the number of records read by the search, with
+	// --probe-reads-metric.
+	Probes *int `json:"probes,omitempty"`
+	// Below and Above are the records next to where the hash would be on a
+	// miss, with --report-nearest. One is missing at the start or the end
+	// of the list.
+	Below *nearestRecord `json:"below,omitempty"`
+	Above *nearestRecord `json:"above,omitempty"`
+	// nearest is set if Below and Above were looked for.
+	nearest bool
+	// showHash makes print say which hash was searched for.
+	showHash bool
+}
+
+func (res searchResult) print(w io.Writer) {
+	if res.Online {
+		fmt.Fprint(w, "searching online")
+	} else {
+		fmt.Fprintf(w, "searching file %q", res.File)
+	}
+	if res.showHash {
+		fmt.Fprintf(w, " for %s", res.Hash)
+	}
+	fmt.Fprint(w, ": ")
+	var probes string
+	if res.Probes != nil {
+		probes = fmt.Sprintf(", in %d probes", *res.Probes)
+	}
+	switch {
+	case res.Error != "":
+		fmt.Fprintln(w, "error:", res.Error)
+	case res.Filtered:
+		fmt.Fprintln(w, "no match (ruled out by the filter).")
+	case res.Online:
+		fmt.Fprintf(w, "hash matched! (count %d)\n", res.Count)
+	case !res.Found && probes != "":
+		fmt.Fprintf(w, "no match (%s).\n", probes[2:])
+	case !res.Found:
+		fmt.Fprintln(w, "no match.")
+	case res.Index == 0:
+		fmt.Fprintf(w, "hash matched! (count %d, byte offset %d%s)\n", res.Count, *res.Offset, probes)
+	default:
+		fmt.Fprintf(w, "hash %d matched! (byte offset %d%s)\n", res.Index, *res.Offset, probes)
+	}
+	if res.nearest && res.Error == "" {
+		res.Below.print(w, "below", "the hash would be the first record")
+		res.Above.print(w, "above", "the hash would be the last record")
+	}
+}
+
+// nearestRecord is a record next to where a hash that isn't in a list would
+// be, for search --report-nearest.
+type nearestRecord struct {
+	Hash string `json:"hash"`
+	// Index is the 1-based record number, only known for fixed-width lists.
+	Index  int64  `json:"index,omitempty"`
+	Offset int64  `json:"offset"`
+	Count  uint64 `json:"count,omitempty"`
+}
+
+// print prints the record as the nearest one on side, or that there is
+// none, which means edge.
+func (n *nearestRecord) print(w io.Writer, side, edge string) {
+	switch {
+	case n == nil:
+		fmt.Fprintf(w, "  nearest %s: none, %s\n", side, edge)
+	case n.Index == 0:
+		fmt.Fprintf(w, "  nearest %s: %s (count %d, byte offset %d)\n", side, n.Hash, n.Count, n.Offset)
+	default:
+		fmt.Fprintf(w, "  nearest %s: %s (hash %d, byte offset %d)\n", side, n.Hash, n.Index, n.Offset)
+	}
+}
+
+// nearestRecords returns the records of filename just below and just above
+// where hashString would be, nil at the start or the end of the list. Their
+// offsets count a skipped BOM, like the ones of matches.
+func nearestRecords(ctx context.Context, filename, hashString string, skipBOM, withCount bool, format pwnedlist.Format, lowercase bool) (*nearestRecord, *nearestRecord, error) {
+	if !isURL(filename) {
+		stream, err := isStream(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		if stream {
+			return nil, nil, errors.New("--report-nearest needs a list that can be seeked, not a gzipped list or stream")
+		}
+	}
+	r, size, c, err := openListAt(ctx, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Close()
+	r, size, bom := listAt(r, size, skipBOM)
+	below, above, err := format.Nearest(r, size, hashString, withCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	record := func(n *pwnedlist.Neighbor) *nearestRecord {
+		if n == nil {
+			return nil
+		}
+		rec := &nearestRecord{Hash: displayHash(n.Hash, lowercase), Offset: bom + n.Offset, Count: n.Count}
+		if !withCount {
+			rec.Index = format.IndexAt(n.Offset) + 1
+		}
+		return rec
+	}
+	return record(below), record(above), nil
+}
+
+// searchSummary sums up results of searching files, like "1 match in
+// shard-03.bin".
+func searchSummary(results []searchResult, files int) string {
+	var matches int
+	var matched []string
+	for _, res := range results {
+		if !res.Found {
+			continue
+		}
+		matches++
+		if !slices.Contains(matched, res.File) {
+			matched = append(matched, res.File)
+		}
+	}
+	switch matches {
+	case 0:
+		return fmt.Sprintf("no match in %d files", files)
+	case 1:
+		return "1 match in " + matched[0]
+	}
+	return fmt.Sprintf("%d matches in %s", matches, strings.Join(matched, ", "))
+}
+
+// traceProbes returns a ProbeFunc that logs every probe on stderr, for
+// search --verbose.
+func traceProbes(format pwnedlist.Format, withCount, lowercase bool) pwnedlist.ProbeFunc {
+	return func(n int, offset int64, b []byte) {
+		hash := displayHash(string(b), lowercase)
+		if withCount {
+			fmt.Fprintf(os.Stderr, "probe %d: byte offset %d, hash %s\n", n, offset, hash)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "probe %d: record %d, byte offset %d, hash %s\n", n, format.IndexAt(offset)+1, offset, hash)
+	}
+}
+
+// searchConcurrently calls search for 0 up to n from up to jobs goroutines,
+// and returns the results in order. Once a search failed no more are started,
+// as the results are only printed up to the first error, so the results
+// after the ones in progress then are left empty.
+func searchConcurrently(n, jobs int, search func(i int) searchResult) []searchResult {
+	results := make([]searchResult, n)
+	next := make(chan int)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < min(jobs, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = search(i)
+				if results[i].Error != "" {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	for i := 0; i < n && !failed.Load(); i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// searchFile searches filename for hashString, and returns the index and the
+// byte offset of the matching record, or -1 twice and pwnedlist.ErrNotFound.
+// With skipBOM a UTF-8 BOM at the start of the list is skipped, and counted
+// in the offset. If index isn't nil, only the block of records it points to
+// is searched.
+func searchFile(ctx context.Context, filename string, hashString string, useMmap, interpolation, skipBOM bool, index *pwnedlist.SparseIndex, format pwnedlist.Format, probe pwnedlist.ProbeFunc) (int64, int64, error) {
+	search := format.SearchProbe
+	switch {
+	case index != nil:
+		search = func(r io.ReaderAt, size int64, hash string, probe pwnedlist.ProbeFunc) (int64, error) {
+			return format.SearchIndexProbe(r, size, index, hash, probe)
+		}
+	case interpolation:
+		search = format.SearchInterpolation
+	}
+	if !isURL(filename) {
+		stream, err := isStream(filename)
+		if err != nil {
+			return -1, -1, err
+		}
+		if stream {
+			if index != nil {
+				return -1, -1, errors.New("a gzipped list or stream is read from the start, it can't be searched with an index")
+			}
+			s, err := openStream(filename)
+			if err != nil {
+				return -1, -1, err
+			}
+			defer s.Close()
+			sr, bom := skipStreamBOM(s, skipBOM)
+			match, err := format.SearchStream(pwnedlist.ContextReader(ctx, sr), hashString)
+			if err != nil {
+				return -1, -1, err
+			}
+			return match, bom + format.OffsetOf(match), nil
+		}
+		zst, err := isZstd(filename)
+		if err != nil {
+			return -1, -1, err
+		}
+		if !skipBOM && index == nil && !zst {
+			s, err := format.OpenSearcher(filename, useMmap)
+			if err != nil {
+				return -1, -1, err
+			}
+			defer s.Close()
+			cs := s.WithContext(ctx)
+			lookup := cs.LookupProbe
+			if interpolation {
+				lookup = cs.LookupInterpolation
+			}
+			offset, found, err := lookup(hashString, probe)
+			if err == nil && !found {
+				err = pwnedlist.ErrNotFound
+			}
+			if err != nil {
+				return -1, -1, err
+			}
+			return format.IndexAt(offset), offset, nil
+		}
+	}
+	// The list is read without memory-mapping it, after the BOM or with the
+	// index, or from a URL or zstd file.
+	r, size, c, err := openListAt(ctx, filename)
+	if err != nil {
+		return -1, -1, err
 	}
-	if fi.Size()%42 != 0 {
-		return -1, fmt.Errorf("file size not a multiple of 42")
+	defer c.Close()
+	r, size, bom := listAt(r, size, skipBOM)
+	match, err := search(r, size, hashString, shiftProbe(probe, bom))
+	if err != nil {
+		return -1, -1, err
 	}
-	hashBytes := []byte(hashString)
-	buf := make([]byte, 42)
-	i := sort.Search(int(fi.Size()/42), func(i int) bool {
+	return match, bom + format.OffsetOf(match), nil
+}
+
+// searchCountFile searches filename, which has HASH:COUNT records, for
+// hashString, and returns the byte offset and the count of the matching
+// record, or -1 for the offset and pwnedlist.ErrNotFound. skipBOM is like
+// for searchFile.
+func searchCountFile(ctx context.Context, filename string, hashString string, skipBOM bool, format pwnedlist.Format, probe pwnedlist.ProbeFunc) (int64, uint64, error) {
+	if !isURL(filename) {
+		stream, err := isStream(filename)
 		if err != nil {
-			return false
+			return -1, 0, err
 		}
-		_, err = f.Seek(int64(i)*42, 0)
+		if stream {
+			s, err := openStream(filename)
+			if err != nil {
+				return -1, 0, err
+			}
+			defer s.Close()
+			sr, bom := skipStreamBOM(s, skipBOM)
+			offset, count, err := format.SearchCountStream(pwnedlist.ContextReader(ctx, sr), hashString)
+			if offset != -1 {
+				offset += bom
+			}
+			return offset, count, err
+		}
+	}
+	r, size, c, err := openListAt(ctx, filename)
+	if err != nil {
+		return -1, 0, err
+	}
+	defer c.Close()
+	r, size, bom := listAt(r, size, skipBOM)
+	offset, count, err := format.SearchCountProbe(r, size, hashString, shiftProbe(probe, bom))
+	if offset != -1 {
+		offset += bom
+	}
+	return offset, count, err
+}
+
+// openListAt opens the list in filename, which is a file, a seekable zstd
+// file or a URL, to be read at random offsets, and returns it with its size.
+// The Closer closes it.
+func openListAt(ctx context.Context, filename string) (io.ReaderAt, int64, io.Closer, error) {
+	if isURL(filename) {
+		u, err := openURL(ctx, filename)
 		if err != nil {
-			return false
+			return nil, 0, nil, err
 		}
-		_, err = f.Read(buf)
+		return u, u.Size(), u, nil
+	}
+	zst, err := isZstd(filename)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if zst {
+		z, err := openSeekableZstd(filename)
 		if err != nil {
-			return false
+			return nil, 0, nil, err
 		}
-		if bytes.Compare(buf[:40], hashBytes) < 0 {
-			return false
+		return pwnedlist.ContextReaderAt(ctx, z), z.Size(), z, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, nil, err
+	}
+	return pwnedlist.ContextReaderAt(ctx, f), fi.Size(), f, nil
+}
+
+// listAt returns the list in the size bytes of r, and its size, without the
+// UTF-8 BOM it may start with if skipBOM is set. The size of the skipped BOM
+// is returned too, to add to the offsets in the list.
+func listAt(r io.ReaderAt, size int64, skipBOM bool) (io.ReaderAt, int64, int64) {
+	if !skipBOM {
+		return r, size, 0
+	}
+	lr, lsize := pwnedlist.SkipBOM(r, size)
+	return lr, lsize, size - lsize
+}
+
+// skipStreamBOM is listAt for a list that can only be read from start to end.
+func skipStreamBOM(r io.Reader, skipBOM bool) (io.Reader, int64) {
+	if !skipBOM {
+		return r, 0
+	}
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(len(pwnedlist.UTF8BOM)); string(b) == pwnedlist.UTF8BOM {
+		br.Discard(len(b))
+		return br, int64(len(b))
+	}
+	return br, 0
+}
+
+// shiftProbe returns probe with bom added to the offsets it's called with,
+// for the offsets to be in the file instead of in the list after its BOM.
+func shiftProbe(probe pwnedlist.ProbeFunc, bom int64) pwnedlist.ProbeFunc {
+	if probe == nil || bom == 0 {
+		return probe
+	}
+	return func(n int, offset int64, hash []byte) {
+		probe(n, bom+offset, hash)
+	}
+}
+
+// createOutput returns stdout, or filename created for writing if it isn't
+// empty. The writes are checked once, when it's closed.
+func createOutput(filename string) (io.WriteCloser, error) {
+	if filename == "" {
+		return &outputWriter{w: os.Stdout}, nil
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &outputWriter{w: f, c: f}, nil
+}
+
+// outputWriter keeps the first error of writing to w, and returns it from
+// Close, so a full disk fails the command and not just the line that didn't
+// fit. Writes after it fail with the same error.
+type outputWriter struct {
+	w   io.Writer
+	c   io.Closer // nil for stdout, which isn't closed
+	err error
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	n, err := o.w.Write(p)
+	o.err = err
+	return n, err
+}
+
+func (o *outputWriter) Close() error {
+	err := o.err
+	if o.c != nil {
+		if cerr := o.c.Close(); err == nil {
+			err = cerr
 		}
-		return true
-	})
-	_, err = f.Seek(int64(i)*42, 0)
+	}
+	return err
+}
+
+// closeOutput closes w, and replaces *err with an error for the exit code of
+// a failure if it didn't write all the output. It's deferred by commands with
+// --output.
+func closeOutput(w io.Closer, err *error) {
+	if cerr := w.Close(); cerr != nil {
+		*err = cli.NewExitError("writing the output: "+cerr.Error(), exitError)
+	}
+}
+
+// isTerminal reports whether f is a terminal, and not just any character
+// device like /dev/null.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// promptPassword asks for a password on the terminal on stdin, with echo
+// turned off while it's typed.
+func promptPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("--prompt needs a terminal on stdin, use --password-stdin to read the password from a pipe")
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+// readPassword reads a password from r, all of it up to EOF. With trim a
+// single trailing newline is dropped, as echo and most editors add one that
+// isn't part of the password.
+func readPassword(r io.Reader, trim bool) (string, error) {
+	b, err := io.ReadAll(r)
 	if err != nil {
-		return -1, err
+		return "", err
+	}
+	pw := string(b)
+	if trim {
+		if pw, _ = strings.CutSuffix(pw, "\n"); len(pw) < len(b) {
+			pw, _ = strings.CutSuffix(pw, "\r")
+		}
+	}
+	return pw, nil
+}
+
+// decodeInput returns r decoded to UTF-8 from encoding, which is utf8,
+// utf16le or utf16be, for passwords typed or saved on Windows, which tends to
+// write UTF-16. A BOM at the start of r overrides encoding, and is dropped.
+// UTF-8 is passed through unchecked, so passwords that aren't valid UTF-8
+// keep their bytes, and with them their hashes.
+func decodeInput(r io.Reader, encoding string) (io.Reader, error) {
+	var fallback transform.Transformer
+	switch encoding {
+	case "utf8":
+		fallback = transform.Nop
+	case "utf16le":
+		fallback = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
+	case "utf16be":
+		fallback = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()
+	default:
+		return nil, fmt.Errorf("invalid --input-encoding %q, it has to be utf8, utf16le or utf16be", encoding)
+	}
+	return transform.NewReader(r, unicode.BOMOverride(fallback)), nil
+}
+
+// readBinaryHash reads a hash of raw bytes from filename, or stdin for "-",
+// and returns it in hexadecimal notation.
+func readBinaryHash(filename string, format pwnedlist.Format) (string, error) {
+	r := io.Reader(os.Stdin)
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
 	}
-	_, err = f.Read(buf)
+	size := format.HashLen / 2
+	b, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
 	if err != nil {
-		return -1, err
+		return "", err
+	}
+	if len(b) > size {
+		return "", fmt.Errorf("more than %d bytes", size)
 	}
-	if bytes.Equal(buf[:40], hashBytes) {
-		return i, nil
+	if len(b) < size {
+		return "", fmt.Errorf("%d bytes instead of %d", len(b), size)
 	}
-	return -1, nil
+	return strings.ToUpper(hex.EncodeToString(b)), nil
 }
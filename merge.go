@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// mergeFiles merges the sorted lists in files into out, which can be "-" for
+// stdout.
+func mergeFiles(out string, files []string, withCount bool, format pwnedlist.Format) error {
+	srcs := make([]io.Reader, len(files))
+	for i, filename := range files {
+		r, err := openStream(filename)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		srcs[i] = r
+	}
+	w, err := createAtomic(out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	n, dups, err := format.Merge(w, srcs, withCount)
+	if err == nil {
+		err = w.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "merged %d records, dropped %d duplicates\n", n, dups)
+	return nil
+}
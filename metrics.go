@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// serverMetrics counts the range requests of serve --metrics, for GET
+// /metrics in the Prometheus text format.
+type serverMetrics struct {
+	mu       sync.Mutex
+	requests map[int]uint64 // by status code
+	latency  histogram
+	records  histogram
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests: make(map[int]uint64),
+		latency:  newHistogram(0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5),
+		records:  newHistogram(100, 200, 400, 600, 800, 1000, 1500, 2000, 5000),
+	}
+}
+
+// observe records a request answered with code after d. records is the
+// number of records in the response, or -1 if it has none.
+func (m *serverMetrics) observe(code int, d time.Duration, records int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[code]++
+	m.latency.observe(d.Seconds())
+	if records >= 0 {
+		m.records.observe(float64(records))
+	}
+}
+
+func (m *serverMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(w, "# HELP pwned_range_requests_total Range requests, by HTTP status code.\n")
+	fmt.Fprintf(w, "# TYPE pwned_range_requests_total counter\n")
+	codes := make([]int, 0, len(m.requests))
+	for code := range m.requests {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "pwned_range_requests_total{code=\"%d\"} %d\n", code, m.requests[code])
+	}
+	m.latency.write(w, "pwned_range_request_duration_seconds", "Time taken to answer range requests.")
+	m.records.write(w, "pwned_range_response_records", "Records in range responses.")
+}
+
+// histogram counts observations in cumulative buckets, like a Prometheus
+// histogram.
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, the last one for the observations above all bounds
+	sum    float64
+}
+
+func newHistogram(bounds ...float64) histogram {
+	return histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i, _ := slices.BinarySearch(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	var n uint64
+	for i, bound := range h.bounds {
+		n += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), n)
+	}
+	n += h.counts[len(h.bounds)]
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, n)
+	fmt.Fprintf(w, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, n)
+}
+
+// statusWriter remembers the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
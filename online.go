@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+)
+
+// searchOnline looks up hash in its range, as returned by the API d talks to.
+// Only the first 5 characters of hash are sent.
+func searchOnline(ctx context.Context, d *downloader, hash string) searchResult {
+	res := searchResult{Online: true, Hash: hash}
+	prefix, err := strconv.ParseUint(hash[:5], 16, 32)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	records, err := d.fetch(ctx, int(prefix))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	for _, record := range bytes.Split(records, []byte("\r\n")) {
+		found, count, ok := bytes.Cut(record, []byte(":"))
+		if !ok || string(found) != hash {
+			continue
+		}
+		res.Found = true
+		res.Count, err = strconv.ParseUint(string(count), 10, 64)
+		if err != nil {
+			res.Error = err.Error()
+		}
+		return res
+	}
+	return res
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// prefixFile calls fn for every record in filename whose hash starts with
+// prefix, which takes a binary search and then a scan of the matching
+// records.
+func prefixFile(filename, prefix string, withCount bool, format pwnedlist.Format, fn func(hash []byte, count uint64) error) error {
+	gzipped, err := isGzip(filename)
+	if err != nil {
+		return err
+	}
+	if gzipped {
+		return errors.New("can't binary search a gzipped list, decompress it first")
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return format.Range(f, fi.Size(), prefix, withCount, fn)
+}
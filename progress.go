@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressMeter shows the progress of the check command on a terminal,
+// updating it in place with ANSI escape sequences.
+type progressMeter struct {
+	w    io.Writer
+	rate bool
+	// recordSize is the size of a fixed-width record, to work out the bytes
+	// checked from the records, unless they're counted by a reader.
+	recordSize       int64
+	counted          bool
+	read             int64
+	start, last      time.Time
+	lastN, lastBytes int64
+}
+
+// newProgressMeter saves the cursor position of w, for the progress to be
+// written there. With rate set the progress also has the records and
+// megabytes per second, since the start and since the last update.
+func newProgressMeter(w io.Writer, rate bool, recordSize int) *progressMeter {
+	fmt.Fprint(w, "\033[s")
+	now := time.Now()
+	return &progressMeter{w: w, rate: rate, recordSize: int64(recordSize), start: now, last: now}
+}
+
+// update shows that n records were checked. It's a pwnedlist.ProgressFunc.
+func (m *progressMeter) update(n int64) {
+	s := humanCount(n)
+	if m.rate {
+		bytes := n * m.recordSize
+		if m.counted {
+			bytes = m.read
+		}
+		now := time.Now()
+		s += fmt.Sprintf(" (%s, now %s)", formatRate(n, bytes, now.Sub(m.start)), formatRate(n-m.lastN, bytes-m.lastBytes, now.Sub(m.last)))
+		m.last, m.lastN, m.lastBytes = now, n, bytes
+	}
+	fmt.Fprintf(m.w, "\033[u\033[K%s ", s)
+}
+
+// clear removes the progress again.
+func (m *progressMeter) clear() {
+	fmt.Fprint(m.w, "\033[u\033[K")
+}
+
+// reader returns r, counting the bytes read from it for the rate.
+func (m *progressMeter) reader(r io.Reader) io.Reader {
+	m.counted = true
+	return countingReader{r, &m.read}
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	*r.n += int64(n)
+	return n, err
+}
+
+// formatRate formats the rate of checking n records of bytes bytes in d.
+func formatRate(n, bytes int64, d time.Duration) string {
+	secs := d.Seconds()
+	if secs <= 0 {
+		return "no records/s measured"
+	}
+	return fmt.Sprintf("%s records/s, %.1f MB/s", humanCount(int64(float64(n)/secs)), float64(bytes)/secs/1e6)
+}
+
+func humanCount(n int64) string {
+	if n > 1000000 {
+		return fmt.Sprintf("%dM", n/1000000)
+	} else if n > 1000 {
+		return fmt.Sprintf("%dK", n/1000)
+	}
+	return fmt.Sprintf("%d", n)
+}
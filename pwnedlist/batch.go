@@ -0,0 +1,45 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"io"
+)
+
+// SearchMany walks the list in r and the sorted hashes side by side, setting
+// found[i] for every hashes[i] it comes across. This reads the list at most
+// once, however many hashes there are. It stops reading as soon as it's past
+// the last hash.
+func (f Format) SearchMany(r io.Reader, hashes [][]byte, found []bool, withCount bool) error {
+	return f.searchMany(r, hashes, withCount, func(q int, _ uint64) { found[q] = true })
+}
+
+// SearchManyCounts is SearchMany for a list with counts, setting counts[i] to
+// the count of every hashes[i] it comes across. The others are left as they
+// are.
+func (f Format) SearchManyCounts(r io.Reader, hashes [][]byte, counts []uint64) error {
+	return f.searchMany(r, hashes, true, func(q int, count uint64) { counts[q] = count })
+}
+
+// searchMany calls match with the index in hashes of every hash it comes
+// across, and its count.
+func (f Format) searchMany(r io.Reader, hashes [][]byte, withCount bool, match func(q int, count uint64)) error {
+	rr := f.newRecordReader(r, withCount, 1<<20)
+	q := 0
+	for q < len(hashes) {
+		hash, count, err := rr.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for q < len(hashes) && bytes.Compare(hashes[q], hash) < 0 {
+			q++
+		}
+		if q < len(hashes) && bytes.Equal(hashes[q], hash) {
+			match(q, count)
+			q++
+		}
+	}
+	return nil
+}
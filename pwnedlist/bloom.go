@@ -0,0 +1,192 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// bloomMagic starts every file written by BloomFilter.WriteTo, followed by
+// bloomVersion.
+var bloomMagic = [8]byte{'P', 'W', 'N', 'B', 'L', 'O', 'O', 'M'}
+
+// bloomVersion is the version of the filter format, which changes whenever
+// the format does. Version 1 had no version in its header.
+const bloomVersion = 2
+
+// bloomHeaderSize is the size of the magic, the version, the hash length,
+// the number of hash functions and the number of 64-bit words of bits.
+const bloomHeaderSize = 28
+
+// BloomFilter is a compact set of hashes that can tell for sure that a hash
+// isn't in a list, without reading the list. It can report a hash that isn't
+// in the list as present, at the false positive rate it was built for.
+type BloomFilter struct {
+	hashLen int
+	k       uint32
+	bits    []uint64
+}
+
+// NewBloomFilter returns an empty filter for n hashes of hashLen characters,
+// sized to give false positives at rate p.
+func NewBloomFilter(n int64, p float64, hashLen int) (*BloomFilter, error) {
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false positive rate %v isn't between 0 and 1", p)
+	}
+	if hashLen < 32 {
+		return nil, fmt.Errorf("hashes of %d characters are too short for a filter", hashLen)
+	}
+	if n < 1 {
+		n = 1
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{
+		hashLen: hashLen,
+		k:       uint32(k),
+		bits:    make([]uint64, (uint64(m)+63)/64),
+	}, nil
+}
+
+// Add adds hash, in uppercase hexadecimal notation, to the filter.
+func (b *BloomFilter) Add(hash []byte) error {
+	h1, h2, err := b.keys(hash)
+	if err != nil {
+		return err
+	}
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < uint64(b.k); i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+	return nil
+}
+
+// MayContain reports whether hash could be in the filter. If it returns
+// false, the hash was never added.
+func (b *BloomFilter) MayContain(hash string) bool {
+	h1, h2, err := b.keys([]byte(hash))
+	if err != nil {
+		return true
+	}
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < uint64(b.k); i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// keys returns the first two 64 bit words of hash. The hashes in the lists
+// are uniformly distributed already, so they don't have to be hashed again.
+func (b *BloomFilter) keys(hash []byte) (uint64, uint64, error) {
+	if len(hash) != b.hashLen || !IsHex(hash) {
+		return 0, 0, fmt.Errorf("%q isn't a hash of %d hexadecimal characters", hash, b.hashLen)
+	}
+	h1, err := strconv.ParseUint(string(hash[:16]), 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	h2, err := strconv.ParseUint(string(hash[16:32]), 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	// An odd step makes sure the k bits aren't all the same one.
+	return h1, h2 | 1, nil
+}
+
+// WriteTo writes the filter to w, in a format ReadBloomFilter reads. The
+// integers in it are little-endian, so a filter can be moved between
+// machines.
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, bloomHeaderSize)
+	header = append(header, bloomMagic[:]...)
+	header = binary.LittleEndian.AppendUint32(header, bloomVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(b.hashLen))
+	header = binary.LittleEndian.AppendUint32(header, b.k)
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(b.bits)))
+	bw.Write(header)
+	word := make([]byte, 8)
+	for _, bits := range b.bits {
+		binary.LittleEndian.PutUint64(word, bits)
+		bw.Write(word)
+	}
+	n := int64(len(header) + 8*len(b.bits))
+	return n, bw.Flush()
+}
+
+// ReadBloomFilter reads a filter written by BloomFilter.WriteTo. It's an
+// error for the filter to be in another version of the format.
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	header := make([]byte, bloomHeaderSize)
+	if _, err := io.ReadFull(br, header[:12]); err != nil {
+		return nil, fmt.Errorf("reading filter header: %v", err)
+	}
+	if [8]byte(header[:8]) != bloomMagic {
+		return nil, errors.New("not a filter written by build-filter")
+	}
+	if err := checkVersion("filter", binary.LittleEndian.Uint32(header[8:]), bloomVersion, "build-filter"); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(br, header[12:]); err != nil {
+		return nil, fmt.Errorf("reading filter header: %v", err)
+	}
+	b := &BloomFilter{
+		hashLen: int(binary.LittleEndian.Uint32(header[12:])),
+		k:       binary.LittleEndian.Uint32(header[16:]),
+	}
+	words := binary.LittleEndian.Uint64(header[20:])
+	if b.k == 0 || words == 0 || words > math.MaxInt/8 {
+		return nil, errors.New("corrupt filter header")
+	}
+	b.bits = make([]uint64, words)
+	word := make([]byte, 8)
+	for i := range b.bits {
+		if _, err := io.ReadFull(br, word); err != nil {
+			return nil, fmt.Errorf("reading filter: %v", err)
+		}
+		b.bits[i] = binary.LittleEndian.Uint64(word)
+	}
+	return b, nil
+}
+
+// HashLen returns the length of the hashes the filter was built for.
+func (b *BloomFilter) HashLen() int {
+	return b.hashLen
+}
+
+// BuildBloomFilter adds all hashes of the list in r to a filter for the given
+// number of records.
+func (f Format) BuildBloomFilter(r io.Reader, withCount bool, records int64, p float64) (*BloomFilter, error) {
+	b, err := NewBloomFilter(records, p, f.HashLen)
+	if err != nil {
+		return nil, err
+	}
+	rr := f.newRecordReader(r, withCount, 1<<20)
+	for n := 1; ; n++ {
+		hash, _, err := rr.next()
+		if err == io.EOF {
+			return b, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, incompleteRecord(n, rr.partial)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Add(hash); err != nil {
+			return nil, fmt.Errorf("hash %d: %w", n, err)
+		}
+	}
+}
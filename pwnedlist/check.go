@@ -0,0 +1,290 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A ProgressFunc is called by Check and CheckParallel while they go through a
+// list, with the number of records checked so far. It's called from the
+// goroutine that called them.
+type ProgressFunc func(records int64)
+
+// CheckOptions controls what Check and CheckParallel look at.
+type CheckOptions struct {
+	// Progress is called every ProgressInterval, if it isn't nil.
+	Progress ProgressFunc
+	// ProgressInterval is how often Progress is called, every 250ms if it's
+	// 0.
+	ProgressInterval time.Duration
+	// WithCount expects HASH:COUNT records instead of fixed-width ones.
+	WithCount bool
+	// Format is the format of the records, SHA1 if it's not set.
+	Format Format
+	// CheckOrder makes it an error for a hash to be less than the one
+	// before it.
+	CheckOrder bool
+	// CheckDuplicates reports hashes that are the same as the one before
+	// them, and makes the check fail if there are any.
+	CheckDuplicates bool
+	// Workers is the number of goroutines used by CheckParallel, which uses
+	// one if it's less than 1.
+	Workers int
+	// BufferSize is the size of the read buffer.
+	BufferSize int
+	// MaxErrors is the number of bad records Check goes past and collects
+	// before it stops, returning them joined with errors.Join. With 0 it
+	// stops at the first one, with a negative value it reads the whole
+	// list. CheckParallel always stops at the first one.
+	MaxErrors int
+	// Start is the number of records before the ones in r, for a reader
+	// that was seeked into a list. Check numbers the records in its errors
+	// from Start+1. CheckParallel doesn't support it.
+	Start int
+	// Limit makes Check stop after this many records, if it's positive.
+	// CheckParallel doesn't support it.
+	Limit int
+	// Histogram counts the records by the first character of their hash.
+	Histogram bool
+	// SkipBOM skips a UTF-8 BOM at the start of the list, instead of
+	// reporting it.
+	SkipBOM bool
+	// Warn, if it isn't nil, is called with the OrderError of every hash
+	// that's out of order and the DuplicateError of every duplicate, which
+	// then don't fail the check. Without it duplicates are only counted, and
+	// fail the check once the whole list was read. CheckParallel calls it
+	// from its workers.
+	Warn func(error)
+}
+
+// CheckSummary describes the records that Check and CheckParallel went
+// through.
+type CheckSummary struct {
+	// Records is the number of valid records.
+	Records int
+	// First and Last are the hashes of the first and last record.
+	First, Last string
+	// Duplicates is the number of duplicate hashes, if they were checked.
+	Duplicates int
+	// OutOfOrder is the number of hashes that were out of order, passed to
+	// Warn.
+	OutOfOrder int
+	// Histogram has the number of valid records whose hash starts with 0,
+	// 1, up to F, if it was asked for.
+	Histogram []int
+}
+
+// OrderError is returned for a hash that's less than the one before it.
+type OrderError struct {
+	N          int
+	Hash, Prev string
+}
+
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("hash %d out of order (%s < %s)", e.N, e.Hash, e.Prev)
+}
+
+// DuplicateError is passed to CheckOptions.Warn for a hash that's the same as
+// the one before it.
+type DuplicateError struct {
+	N int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("hash %d duplicates hash %d", e.N, e.N-1)
+}
+
+// Check reads the whole list in r, and returns an error for the first record
+// that isn't in the expected format, or for the first MaxErrors ones. On
+// error the summary covers the valid records before Check stopped.
+func Check(r io.Reader, opts CheckOptions) (CheckSummary, error) {
+	f := opts.format()
+	br := bufio.NewReaderSize(r, opts.BufferSize)
+	buf := make([]byte, f.RecordSize)
+	readRecord := func() ([]byte, error) {
+		n, err := io.ReadFull(br, buf)
+		return buf[:n], err
+	}
+	if opts.WithCount {
+		readRecord = func() ([]byte, error) {
+			line, err := br.ReadSlice('\n')
+			if err == io.EOF && len(line) > 0 {
+				err = nil
+			}
+			return line, err
+		}
+	}
+	if opts.Limit > 0 {
+		next, left := readRecord, opts.Limit
+		readRecord = func() ([]byte, error) {
+			if left == 0 {
+				return nil, io.EOF
+			}
+			left--
+			return next()
+		}
+	}
+	prev := make([]byte, f.HashLen)
+	var sum CheckSummary
+	if opts.Histogram {
+		sum.Histogram = make([]int, 16)
+	}
+	summary := func() CheckSummary {
+		if sum.Records > 0 {
+			sum.Last = string(prev)
+		}
+		return sum
+	}
+	var errs []error
+	bad := func(err error) bool {
+		errs = append(errs, err)
+		return opts.MaxErrors >= 0 && len(errs) >= max(opts.MaxErrors, 1)
+	}
+	joined := func() error {
+		if len(errs) == 1 {
+			return errs[0]
+		}
+		return errors.Join(errs...)
+	}
+	if opts.Start == 0 {
+		if b, _ := br.Peek(len(UTF8BOM)); opts.SkipBOM && string(b) == UTF8BOM {
+			br.Discard(len(UTF8BOM))
+		}
+		if err := f.checkStart(br); err != nil {
+			return sum, err
+		}
+	}
+	n := opts.Start
+	interval := opts.progressInterval()
+	last := time.Now()
+	for {
+		n++
+		record, err := readRecord()
+		if err == io.EOF {
+			if sum.Duplicates > 0 && opts.Warn == nil {
+				errs = append(errs, fmt.Errorf("%d duplicate hashes found", sum.Duplicates))
+			}
+			return summary(), joined()
+		}
+		if err == io.ErrUnexpectedEOF {
+			err = incompleteRecord(n, len(record))
+		}
+		if err != nil {
+			errs = append(errs, err)
+			return summary(), joined()
+		}
+		if opts.WithCount {
+			err = f.checkCountRecord(n, record)
+		} else {
+			err = f.checkRecord(n, record)
+		}
+		if err != nil {
+			if bad(err) {
+				return summary(), joined()
+			}
+			continue
+		}
+		hash := record[:f.HashLen]
+		if opts.CheckOrder && sum.Records > 0 {
+			if err := checkOrder(n, prev, hash); err != nil && opts.Warn != nil {
+				sum.OutOfOrder++
+				opts.Warn(err)
+			} else if err != nil && bad(err) {
+				return summary(), joined()
+			}
+		}
+		if opts.CheckDuplicates && sum.Records > 0 {
+			if err := checkDuplicate(n, prev, hash); err != nil {
+				sum.Duplicates++
+				if opts.Warn != nil {
+					opts.Warn(err)
+				}
+			}
+		}
+		if sum.Records == 0 {
+			sum.First = string(hash)
+		}
+		sum.Records++
+		if sum.Histogram != nil {
+			sum.Histogram[nibble(hash[0])]++
+		}
+		copy(prev, hash)
+		// Looking at the clock for every record would slow the check down.
+		if opts.Progress != nil && n%4096 == 0 && time.Since(last) >= interval {
+			last = time.Now()
+			opts.Progress(int64(n - opts.Start))
+		}
+	}
+}
+
+// nibble returns the value of the uppercase hexadecimal character c.
+func nibble(c byte) int {
+	if c <= '9' {
+		return int(c - '0')
+	}
+	return int(c-'A') + 10
+}
+
+func (o CheckOptions) progressInterval() time.Duration {
+	if o.ProgressInterval <= 0 {
+		return 250 * time.Millisecond
+	}
+	return o.ProgressInterval
+}
+
+func (o CheckOptions) format() Format {
+	if o.Format == (Format{}) {
+		return SHA1
+	}
+	return o.Format
+}
+
+// UTF8BOM is the byte order mark some editors start UTF-8 text files with.
+const UTF8BOM = "\xEF\xBB\xBF"
+
+// checkStart looks at the start of a list, without consuming it, for a UTF-8
+// BOM or a header line, which would otherwise be reported as a first record
+// with characters other than [0-9A-F]. A first line with letters past F in
+// its hash is taken for a header, like "hash:count".
+func (f Format) checkStart(br *bufio.Reader) error {
+	start, _ := br.Peek(f.RecordSize + 32)
+	if bytes.HasPrefix(start, []byte(UTF8BOM)) {
+		return badFormat("file starts with a UTF-8 BOM")
+	}
+	line, _, _ := bytes.Cut(start, []byte("\n"))
+	hash := line[:min(len(line), f.HashLen)]
+	if bytes.ContainsFunc(hash, func(r rune) bool { return r > 'f' && r <= 'z' || r > 'F' && r <= 'Z' }) {
+		return badFormat("first line doesn't look like a hash record: %q", bytes.TrimRight(line, "\r"))
+	}
+	return nil
+}
+
+func (f Format) checkRecord(n int, record []byte) error {
+	if !IsHex(record[:f.HashLen]) {
+		return badRecord(int64(n), "contained characters other than [0-9A-F]")
+	}
+	if !bytes.HasSuffix(record[f.HashLen:f.RecordSize], []byte(f.LineEnding())) {
+		return badRecord(int64(n), "didn't end with %s", f.lineEndingName())
+	}
+	return nil
+}
+
+func checkOrder(n int, prev, hash []byte) error {
+	if bytes.Compare(hash, prev) < 0 {
+		return &OrderError{N: n, Hash: string(hash), Prev: string(prev)}
+	}
+	return nil
+}
+
+// checkDuplicate returns a DuplicateError for hash n if it's the same as
+// prev.
+func checkDuplicate(n int, prev, hash []byte) error {
+	if bytes.Equal(hash, prev) {
+		return &DuplicateError{N: n}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package pwnedlist
+
+import (
+	"context"
+	"io"
+)
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ContextReader returns a reader that fails with ctx.Err() once ctx is done.
+// Passing it to the functions that scan a list, like SearchStream or
+// SearchMany, makes them stop at the next read.
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return contextReader{ctx, r}
+}
+
+type contextReaderAt struct {
+	ctx context.Context
+	r   io.ReaderAt
+}
+
+func (r contextReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.ReadAt(p, off)
+}
+
+// ContextReaderAt returns a reader that fails with ctx.Err() once ctx is
+// done. Passing it to Search, SearchCount or Range makes them stop at the next
+// probe.
+func ContextReaderAt(ctx context.Context, r io.ReaderAt) io.ReaderAt {
+	return contextReaderAt{ctx, r}
+}
+
+// CheckContext is Check, returning ctx.Err() once ctx is done.
+func CheckContext(ctx context.Context, r io.Reader, opts CheckOptions) (CheckSummary, error) {
+	sum, err := Check(ContextReader(ctx, r), opts)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return sum, err
+}
+
+// CheckParallelContext is CheckParallel, returning ctx.Err() once ctx is
+// done.
+func CheckParallelContext(ctx context.Context, r io.ReaderAt, size int64, opts CheckOptions) (CheckSummary, error) {
+	sum, err := CheckParallel(ContextReaderAt(ctx, r), size, opts)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return sum, err
+}
+
+// WithContext returns a copy of s whose lookups fail with ctx.Err() once
+// ctx is done. The copy shares the open list with s, and shouldn't be closed.
+func (s *Searcher) WithContext(ctx context.Context) *Searcher {
+	s2 := *s
+	s2.r = ContextReaderAt(ctx, s.r)
+	return &s2
+}
@@ -0,0 +1,42 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Convert reads the HASH:COUNT records in src, and writes them to dst as
+// fixed-width records without counts, padded with spaces up to the line
+// ending of f. It returns the number of converted records. If warn is not nil, it's called for every hash that is out of
+// order, which doesn't stop the conversion.
+func (f Format) Convert(dst io.Writer, src io.Reader, warn func(error)) (int, error) {
+	br := bufio.NewReaderSize(src, 1<<20)
+	bw := bufio.NewWriterSize(dst, 1<<20)
+	prev := make([]byte, f.HashLen)
+	out := bytes.Repeat([]byte(" "), f.RecordSize)
+	copy(out[f.RecordSize-len(f.LineEnding()):], f.LineEnding())
+	for n := 1; ; n++ {
+		record, err := br.ReadSlice('\n')
+		if err == io.EOF && len(record) == 0 {
+			return n - 1, bw.Flush()
+		}
+		if err != nil && err != io.EOF {
+			return n - 1, err
+		}
+		if err := f.checkCountRecord(n, record); err != nil {
+			return n - 1, err
+		}
+		hash := record[:f.HashLen]
+		if warn != nil && n > 1 {
+			if err := checkOrder(n, prev, hash); err != nil {
+				warn(err)
+			}
+		}
+		copy(prev, hash)
+		copy(out, hash)
+		if _, err := bw.Write(out); err != nil {
+			return n - 1, err
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+func (f Format) checkCountRecord(n int, record []byte) error {
+	if len(record) < f.HashLen || !IsHex(record[:f.HashLen]) {
+		return badRecord(int64(n), "contained characters other than [0-9A-F]")
+	}
+	if len(record) < f.HashLen+4 || record[f.HashLen] != ':' {
+		return badRecord(int64(n), "wasn't followed by a colon and count")
+	}
+	end := len(record) - 2
+	if record[end] != '\r' || record[end+1] != '\n' {
+		return badRecord(int64(n), "didn't end with CR + LF")
+	}
+	// parseCount takes any number, but the list writes counts as plain
+	// decimals, so leading zeros mean the record is corrupt.
+	count := record[f.HashLen+1 : end]
+	if len(count) > 1 && count[0] == '0' {
+		return badRecord(int64(n), "has an invalid count field: %q has a leading zero", count)
+	}
+	if _, err := parseCount(count); err != nil {
+		return badRecord(int64(n), "has an invalid count field: %v", err)
+	}
+	return nil
+}
+
+func parseCount(b []byte) (uint64, error) {
+	count, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, errors.New("count must be positive")
+	}
+	return count, nil
+}
+
+// parseCountRecord splits a HASH:COUNT\r\n record in its hash and count.
+func (f Format) parseCountRecord(record []byte) ([]byte, uint64, error) {
+	if len(record) < f.HashLen+4 || record[f.HashLen] != ':' || !bytes.HasSuffix(record, []byte("\r\n")) {
+		return nil, 0, badFormat("malformed record %q", record)
+	}
+	count, err := parseCount(record[f.HashLen+1 : len(record)-2])
+	if err != nil {
+		return nil, 0, err
+	}
+	return record[:f.HashLen], count, nil
+}
+
+// SearchCount runs a binary search over the byte offsets of a list with
+// variable length HASH:COUNT records, which is size bytes long. Every probe is
+// snapped back to the start of the record it landed in. It returns the byte
+// offset of the matching record and its count, or -1 and ErrNotFound if the
+// hash isn't in the list.
+func (f Format) SearchCount(r io.ReaderAt, size int64, hash string) (int64, uint64, error) {
+	return f.SearchCountProbe(r, size, hash, nil)
+}
+
+// SearchCountProbe is SearchCount, calling probe for every probe if it isn't
+// nil.
+func (f Format) SearchCountProbe(r io.ReaderAt, size int64, hash string, probe ProbeFunc) (int64, uint64, error) {
+	hashBytes := []byte(hash)
+	buf := make([]byte, f.maxCountRecord())
+	low, err := f.lowerBoundCount(r, size, hashBytes, buf, probe)
+	if err != nil {
+		return -1, 0, err
+	}
+	if low == size {
+		return -1, 0, ErrNotFound
+	}
+	record, err := f.readRecordAt(r, low, buf)
+	if err != nil {
+		return -1, 0, err
+	}
+	found, count, err := f.parseCountRecord(record)
+	if err != nil {
+		return -1, 0, fmt.Errorf("at byte offset %d: %w", low, err)
+	}
+	if !bytes.Equal(found, hashBytes) {
+		return -1, 0, ErrNotFound
+	}
+	return low, count, nil
+}
+
+// lowerBoundCount returns the byte offset of the first HASH:COUNT record with
+// a hash >= hash.
+func (f Format) lowerBoundCount(r io.ReaderAt, size int64, hash []byte, buf []byte, probe ProbeFunc) (int64, error) {
+	low, high := int64(0), size
+	for n := 1; low < high; n++ {
+		mid := low + (high-low)/2
+		start, err := recordStart(r, mid, buf)
+		if err != nil {
+			return -1, err
+		}
+		record, err := f.readRecordAt(r, start, buf)
+		if err != nil {
+			return -1, err
+		}
+		if probe != nil {
+			probe(n, start, record[:f.HashLen])
+		}
+		if bytes.Compare(record[:f.HashLen], hash) < 0 {
+			low = start + int64(len(record))
+		} else {
+			high = start
+		}
+	}
+	return low, nil
+}
+
+// recordStart returns the offset of the first byte of the record containing
+// the byte at offset.
+func recordStart(r io.ReaderAt, offset int64, buf []byte) (int64, error) {
+	from := offset - int64(len(buf))
+	if from < 0 {
+		from = 0
+	}
+	err := readFullAt(r, buf[:offset-from], from)
+	if err != nil {
+		return -1, err
+	}
+	i := bytes.LastIndexByte(buf[:offset-from], '\n')
+	if i == -1 {
+		if from > 0 {
+			return -1, badFormat("no record boundary found before byte offset %d", offset)
+		}
+		return 0, nil
+	}
+	return from + int64(i) + 1, nil
+}
+
+// readRecordAt reads the record starting at offset, including its line
+// ending. The returned slice aliases buf.
+func (f Format) readRecordAt(r io.ReaderAt, offset int64, buf []byte) ([]byte, error) {
+	n, err := r.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	i := bytes.IndexByte(buf[:n], '\n')
+	if i < f.HashLen {
+		return nil, badFormat("malformed record at byte offset %d", offset)
+	}
+	return buf[:i+1], nil
+}
+
+// SearchCountStream is the linear scan counterpart of SearchCount, for
+// readers that can't be seeked.
+func (f Format) SearchCountStream(r io.Reader, hash string) (int64, uint64, error) {
+	hashBytes := []byte(hash)
+	br := bufio.NewReader(r)
+	var offset int64
+	for {
+		record, err := br.ReadSlice('\n')
+		if err == io.EOF && len(record) == 0 {
+			return -1, 0, ErrNotFound
+		}
+		if err != nil && err != io.EOF {
+			return -1, 0, err
+		}
+		found, count, err := f.parseCountRecord(record)
+		if err != nil {
+			return -1, 0, fmt.Errorf("at byte offset %d: %w", offset, err)
+		}
+		switch bytes.Compare(found, hashBytes) {
+		case 0:
+			return offset, count, nil
+		case 1:
+			return -1, 0, ErrNotFound
+		}
+		offset += int64(len(record))
+	}
+}
@@ -0,0 +1,78 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Dedupe copies the sorted list in src to dst, dropping every hash that's the
+// same as the one before it. For lists with counts, the counts of the dropped
+// records are added to the one that's kept. It returns the number of written
+// records and the number of dropped duplicates. A list that isn't sorted is an
+// error, as duplicates wouldn't be next to each other. Records of lists
+// without counts are written as they are, the first of duplicates is kept.
+func (f Format) Dedupe(dst io.Writer, src io.Reader, withCount bool) (written, dups int, err error) {
+	rr := f.newRecordReader(src, withCount, 1<<20)
+	bw := bufio.NewWriterSize(dst, 1<<20)
+	prev := make([]byte, 0, f.HashLen)
+	// record is the whole record of prev, for lists without counts.
+	record := make([]byte, 0, f.RecordSize)
+	var count uint64
+	flush := func() error {
+		written++
+		if !withCount {
+			_, err := bw.Write(record)
+			return err
+		}
+		bw.Write(prev)
+		bw.WriteByte(':')
+		bw.WriteString(strconv.FormatUint(count, 10))
+		_, err := bw.WriteString("\r\n")
+		return err
+	}
+	for n := 1; ; n++ {
+		hash, c, err := rr.next()
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			err = incompleteRecord(n, rr.partial)
+		}
+		if err != nil {
+			return written, dups, err
+		}
+		if !IsHex(hash) {
+			return written, dups, badRecord(int64(n), "contained characters other than [0-9A-F]")
+		}
+		if n > 1 {
+			if err := checkOrder(n, prev, hash); err != nil {
+				return written, dups, err
+			}
+			if bytes.Equal(hash, prev) {
+				dups++
+				if count+c < count {
+					return written, dups, fmt.Errorf("hash %d: count overflows", n)
+				}
+				count += c
+				continue
+			}
+			if err := flush(); err != nil {
+				return written, dups, err
+			}
+		}
+		prev = append(prev[:0], hash...)
+		if !withCount {
+			record = append(record[:0], rr.buf...)
+		}
+		count = c
+	}
+	if len(prev) > 0 {
+		if err := flush(); err != nil {
+			return written, dups, err
+		}
+	}
+	return written, dups, bw.Flush()
+}
@@ -0,0 +1,94 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// DiffStats counts the records Diff found to differ between two lists.
+type DiffStats struct {
+	// Added is the number of hashes in the new list and not the old one.
+	Added int
+	// Removed is the number of hashes in the old list and not the new one.
+	Removed int
+	// Changed is the number of hashes in both lists with a different count.
+	Changed int
+}
+
+// Diff walks the sorted lists oldList and newList side by side, reading each
+// once, and writes the records of newList that aren't in oldList to added.
+// For lists with counts that includes the records of hashes whose count
+// changed, with their new count. Unless removed is nil, the records of the
+// hashes that are only in oldList are written to it. A list that isn't sorted
+// is an error, which calls oldList list 1 and newList list 2, like Merge.
+// Records of lists without counts are written as they are.
+func (f Format) Diff(added, removed io.Writer, oldList, newList io.Reader, withCount bool) (DiffStats, error) {
+	var stats DiffStats
+	aw := bufio.NewWriterSize(added, 1<<20)
+	var rw *bufio.Writer
+	if removed != nil {
+		rw = bufio.NewWriterSize(removed, 1<<20)
+	}
+	// Fixed-width records are written whole, like Merge does.
+	write := func(w *bufio.Writer, s *mergeSource) {
+		if !withCount {
+			w.Write(s.rr.buf)
+			return
+		}
+		w.Write(s.hash)
+		w.WriteByte(':')
+		w.WriteString(strconv.FormatUint(s.count, 10))
+		w.WriteString("\r\n")
+	}
+	o := &mergeSource{index: 0, rr: f.newRecordReader(oldList, withCount, 1<<20)}
+	n := &mergeSource{index: 1, rr: f.newRecordReader(newList, withCount, 1<<20)}
+	oerr, nerr := o.advance(), n.advance()
+	for oerr == nil || nerr == nil {
+		if oerr != nil && oerr != io.EOF {
+			return stats, oerr
+		}
+		if nerr != nil && nerr != io.EOF {
+			return stats, nerr
+		}
+		var c int
+		switch {
+		case oerr == io.EOF:
+			c = 1
+		case nerr == io.EOF:
+			c = -1
+		default:
+			c = bytes.Compare(o.hash, n.hash)
+		}
+		switch {
+		case c < 0:
+			if rw != nil {
+				write(rw, o)
+			}
+			stats.Removed++
+			oerr = o.advance()
+		case c > 0:
+			write(aw, n)
+			stats.Added++
+			nerr = n.advance()
+		default:
+			if withCount && o.count != n.count {
+				write(aw, n)
+				stats.Changed++
+			}
+			oerr, nerr = o.advance(), n.advance()
+		}
+	}
+	for _, err := range []error{oerr, nerr} {
+		if err != io.EOF {
+			return stats, err
+		}
+	}
+	if rw != nil {
+		if err := rw.Flush(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, aw.Flush()
+}
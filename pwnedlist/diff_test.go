@@ -0,0 +1,90 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testFormats are fixed-width formats whose records aren't just a hash and CR
+// + LF.
+var testFormats = []struct {
+	name   string
+	format Format
+}{
+	{"LF", Format{HashLen: 40, RecordSize: 41, LF: true}},
+	{"padded", Format{HashLen: 40, RecordSize: 48}},
+	{"padded LF", Format{HashLen: 32, RecordSize: 36, LF: true}},
+}
+
+// testRecords returns the records of hashes in format f, padded with spaces.
+func testRecords(f Format, hashes ...string) string {
+	var b strings.Builder
+	end := f.LineEnding()
+	for _, hash := range hashes {
+		b.WriteString(hash + strings.Repeat(" ", f.RecordSize-f.HashLen-len(end)) + end)
+	}
+	return b.String()
+}
+
+func TestDiffKeepsRecords(t *testing.T) {
+	for _, tt := range testFormats {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testHashes(5, tt.format.HashLen)
+			oldList := testRecords(tt.format, h[0], h[1], h[3])
+			newList := testRecords(tt.format, h[1], h[2], h[3], h[4])
+			var added, removed bytes.Buffer
+			if _, err := tt.format.Diff(&added, &removed, strings.NewReader(oldList), strings.NewReader(newList), false); err != nil {
+				t.Fatal(err)
+			}
+			if want := testRecords(tt.format, h[2], h[4]); added.String() != want {
+				t.Errorf("added %q, want %q", added.String(), want)
+			}
+			if want := testRecords(tt.format, h[0]); removed.String() != want {
+				t.Errorf("removed %q, want %q", removed.String(), want)
+			}
+		})
+	}
+}
+
+func TestDedupeKeepsRecords(t *testing.T) {
+	for _, tt := range testFormats {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testHashes(3, tt.format.HashLen)
+			var out bytes.Buffer
+			written, dups, err := tt.format.Dedupe(&out, strings.NewReader(testRecords(tt.format, h[0], h[0], h[1], h[2], h[2], h[2])), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if written != 3 || dups != 3 {
+				t.Errorf("wrote %d records and dropped %d duplicates, want 3 and 3", written, dups)
+			}
+			if want := testRecords(tt.format, h...); out.String() != want {
+				t.Errorf("wrote %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestConvertFormat(t *testing.T) {
+	for _, tt := range testFormats {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testHashes(3, tt.format.HashLen)
+			var in strings.Builder
+			for i, hash := range h {
+				fmt.Fprintf(&in, "%s:%d\r\n", hash, i+1)
+			}
+			var out bytes.Buffer
+			if _, err := tt.format.Convert(&out, strings.NewReader(in.String()), nil); err != nil {
+				t.Fatal(err)
+			}
+			if want := testRecords(tt.format, h...); out.String() != want {
+				t.Errorf("wrote %q, want %q", out.String(), want)
+			}
+			if _, err := Check(&out, CheckOptions{Format: tt.format, CheckOrder: true}); err != nil {
+				t.Errorf("converted list fails the check: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchEmptyList(t *testing.T) {
+	hash := testHashes(1, SHA1.HashLen)[0]
+	empty := bytes.NewReader(nil)
+	if i, err := SHA1.Search(empty, 0, hash); i != -1 || !errors.Is(err, ErrNotFound) {
+		t.Errorf("Search = %d, %v, want -1, ErrNotFound", i, err)
+	}
+	if offset, count, err := SHA1.SearchCount(empty, 0, hash); offset != -1 || count != 0 || !errors.Is(err, ErrNotFound) {
+		t.Errorf("SearchCount = %d, %d, %v, want -1, 0, ErrNotFound", offset, count, err)
+	}
+	if i, err := SHA1.SearchStream(empty, hash); i != -1 || !errors.Is(err, ErrNotFound) {
+		t.Errorf("SearchStream = %d, %v, want -1, ErrNotFound", i, err)
+	}
+}
+
+func TestSearcherEmptyList(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(name, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, useMmap := range []bool{false, true} {
+		s, err := SHA1.OpenSearcher(name, useMmap)
+		if err != nil {
+			t.Fatalf("OpenSearcher(useMmap %v): %v", useMmap, err)
+		}
+		if n := s.Records(); n != 0 {
+			t.Errorf("Records = %d, want 0", n)
+		}
+		offset, found, err := s.Lookup(testHashes(1, SHA1.HashLen)[0])
+		if offset != -1 || found || err != nil {
+			t.Errorf("Lookup = %d, %v, %v, want -1, false, nil", offset, found, err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckEmptyList(t *testing.T) {
+	for _, withCount := range []bool{false, true} {
+		sum, err := Check(bytes.NewReader(nil), CheckOptions{WithCount: withCount, CheckOrder: true, CheckDuplicates: true})
+		if err != nil || sum.Records != 0 {
+			t.Errorf("Check(withCount %v) = %d records, %v, want 0, nil", withCount, sum.Records, err)
+		}
+	}
+	sum, err := CheckParallel(bytes.NewReader(nil), 0, CheckOptions{Workers: 4, CheckOrder: true, CheckDuplicates: true})
+	if err != nil || sum.Records != 0 {
+		t.Errorf("CheckParallel = %d records, %v, want 0, nil", sum.Records, err)
+	}
+}
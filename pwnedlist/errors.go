@@ -0,0 +1,52 @@
+package pwnedlist
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by the searches for a hash that isn't in the list,
+// together with an index or byte offset of -1.
+var ErrNotFound = errors.New("hash not found")
+
+// BadFormatError is the error for a list that isn't in the format it's read
+// with, like a record with characters other than [0-9A-F] in its hash or a
+// file whose size isn't a multiple of the record size. A hash that's out of
+// order is an OrderError instead. Errors returned by the reader of a list are
+// passed on, wrapped in context like the byte offset they happened at, so
+// they can be told apart with errors.Is and errors.As.
+type BadFormatError struct {
+	// Record is the 1-based number of the record that's wrong, or 0 if the
+	// error isn't about a single record, or its number isn't known, like in
+	// a list with counts read at a byte offset.
+	Record int64
+	Reason string
+}
+
+func (e *BadFormatError) Error() string {
+	if e.Record == 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("hash %d %s", e.Record, e.Reason)
+}
+
+// badRecord returns a BadFormatError for record n, with a reason formatted
+// like fmt.Sprintf does.
+func badRecord(n int64, format string, a ...any) error {
+	return &BadFormatError{Record: n, Reason: fmt.Sprintf(format, a...)}
+}
+
+// badFormat returns a BadFormatError that isn't about a single record.
+func badFormat(format string, a ...any) error {
+	return &BadFormatError{Reason: fmt.Sprintf(format, a...)}
+}
+
+// checkVersion returns an error for a file of kind, like "index", in version
+// of its format instead of want. The version of a file from before the format
+// had one is whatever field came after the magic, so it isn't reported.
+func checkVersion(kind string, version, want uint32, command string) error {
+	if version == want {
+		return nil
+	}
+	return fmt.Errorf("%s isn't in format version %d, the one this version of pwned reads, rebuild it with %s", kind, want, command)
+}
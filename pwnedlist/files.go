@@ -0,0 +1,69 @@
+package pwnedlist
+
+import (
+	"errors"
+	"os"
+)
+
+// Result is the outcome of searching a single list file for a hash.
+type Result struct {
+	File  string
+	Found bool
+	// Index is the 0-based record number of the match, only known for
+	// fixed-width lists. It's -1 otherwise.
+	Index int64
+	// Offset is the byte offset of the matching record.
+	Offset int64
+	// Count is the count of the matching HASH:COUNT record.
+	Count uint64
+	// Err is the error searching the file, nil for a miss. It's a
+	// BadFormatError if the file isn't a list in the format searched for.
+	Err error
+}
+
+// SearchFiles binary searches every file in paths for hash, which is in
+// uppercase hexadecimal notation, and returns a result for each of them, in
+// order. With withCount set the files have HASH:COUNT records. The files
+// are read at random positions, so they can't be gzipped.
+func (f Format) SearchFiles(paths []string, hash string, withCount bool) []Result {
+	results := make([]Result, len(paths))
+	for i, path := range paths {
+		res := &results[i]
+		res.File, res.Index, res.Offset = path, -1, -1
+		if withCount {
+			res.Offset, res.Count, res.Err = f.searchCountFile(path, hash)
+			res.Found = res.Offset != -1
+			if errors.Is(res.Err, ErrNotFound) {
+				res.Err = nil
+			}
+			continue
+		}
+		s, err := f.OpenSearcher(path, true)
+		if err != nil {
+			res.Err = err
+			continue
+		}
+		offset, found, err := s.Lookup(hash)
+		if cerr := s.Close(); err == nil {
+			err = cerr
+		}
+		if found {
+			res.Found, res.Index, res.Offset = true, f.IndexAt(offset), offset
+		}
+		res.Err = err
+	}
+	return results
+}
+
+func (f Format) searchCountFile(path, hash string) (int64, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return -1, 0, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return -1, 0, err
+	}
+	return f.SearchCount(file, fi.Size(), hash)
+}
@@ -0,0 +1,140 @@
+package pwnedlist
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format describes the records of a list.
+type Format struct {
+	// HashLen is the number of hexadecimal characters in a hash.
+	HashLen int
+	// RecordSize is the size of a fixed-width record, including its line
+	// ending. Any bytes between the hash and the line ending are ignored.
+	RecordSize int
+	// LF makes fixed-width records end with just LF instead of CR + LF.
+	LF bool
+}
+
+var (
+	// SHA1 is the format of the SHA-1 list: 40 characters and CR + LF.
+	SHA1 = Format{HashLen: 40, RecordSize: 42}
+	// NTLM is the format of the NTLM list: 32 characters and CR + LF.
+	NTLM = Format{HashLen: 32, RecordSize: 34}
+)
+
+// Validate returns an error if f can't describe a list, because it has no
+// room for the hash and the line ending in a record.
+func (f Format) Validate() error {
+	if f.HashLen < 1 {
+		return fmt.Errorf("hash length %d isn't positive", f.HashLen)
+	}
+	if f.LF && f.RecordSize < f.HashLen+1 || !f.LF && f.RecordSize < f.HashLen+2 {
+		return fmt.Errorf("record size %d has no room for a hash of %d characters and %s", f.RecordSize, f.HashLen, f.lineEndingName())
+	}
+	return nil
+}
+
+// LineEnding returns the line ending of fixed-width records.
+func (f Format) LineEnding() string {
+	if f.LF {
+		return "\n"
+	}
+	return "\r\n"
+}
+
+// OffsetOf returns the byte offset of the fixed-width record with the 0-based
+// index.
+func (f Format) OffsetOf(index int64) int64 {
+	return index * int64(f.RecordSize)
+}
+
+// IndexAt returns the 0-based index of the fixed-width record that the byte at
+// offset is part of.
+func (f Format) IndexAt(offset int64) int64 {
+	return offset / int64(f.RecordSize)
+}
+
+func (f Format) lineEndingName() string {
+	if f.LF {
+		return "LF"
+	}
+	return "CR + LF"
+}
+
+// ParseHash returns hash in uppercase, after checking it's a hash of this
+// format in hexadecimal notation.
+func (f Format) ParseHash(hash string) (string, error) {
+	hash = strings.ToUpper(hash)
+	if len(hash) != f.HashLen || !IsHex([]byte(hash)) {
+		return "", fmt.Errorf("%q isn't a hash of %d hexadecimal characters", hash, f.HashLen)
+	}
+	return hash, nil
+}
+
+// maxCountRecord is the longest possible HASH:COUNT record: the hash, a colon,
+// a 20 digit count and CR + LF.
+func (f Format) maxCountRecord() int {
+	return f.HashLen + 1 + 20 + 2
+}
+
+// Search is SHA1.Search.
+func Search(r io.ReaderAt, size int64, hash string) (int64, error) {
+	return SHA1.Search(r, size, hash)
+}
+
+// SearchStream is SHA1.SearchStream.
+func SearchStream(r io.Reader, hash string) (int64, error) {
+	return SHA1.SearchStream(r, hash)
+}
+
+// SearchCount is SHA1.SearchCount.
+func SearchCount(r io.ReaderAt, size int64, hash string) (int64, uint64, error) {
+	return SHA1.SearchCount(r, size, hash)
+}
+
+// SearchCountStream is SHA1.SearchCountStream.
+func SearchCountStream(r io.Reader, hash string) (int64, uint64, error) {
+	return SHA1.SearchCountStream(r, hash)
+}
+
+// SearchMany is SHA1.SearchMany.
+func SearchMany(r io.Reader, hashes [][]byte, found []bool, withCount bool) error {
+	return SHA1.SearchMany(r, hashes, found, withCount)
+}
+
+// Range is SHA1.Range.
+func Range(r io.ReaderAt, size int64, prefix string, withCount bool, fn func(hash []byte, count uint64) error) error {
+	return SHA1.Range(r, size, prefix, withCount, fn)
+}
+
+// Nearest is SHA1.Nearest.
+func Nearest(r io.ReaderAt, size int64, hash string, withCount bool) (*Neighbor, *Neighbor, error) {
+	return SHA1.Nearest(r, size, hash, withCount)
+}
+
+// Dedupe is SHA1.Dedupe.
+func Dedupe(dst io.Writer, src io.Reader, withCount bool) (int, int, error) {
+	return SHA1.Dedupe(dst, src, withCount)
+}
+
+// Merge is SHA1.Merge.
+func Merge(dst io.Writer, srcs []io.Reader, withCount bool) (int, int, error) {
+	return SHA1.Merge(dst, srcs, withCount)
+}
+
+// Diff is SHA1.Diff.
+func Diff(added, removed io.Writer, oldList, newList io.Reader, withCount bool) (DiffStats, error) {
+	return SHA1.Diff(added, removed, oldList, newList, withCount)
+}
+
+// Summarize is SHA1.Summarize.
+func Summarize(r io.Reader, withCount bool) (Stats, error) {
+	return SHA1.Summarize(r, withCount)
+}
+
+// Convert is SHA1.Convert.
+func Convert(dst io.Writer, src io.Reader, warn func(error)) (int, error) {
+	return SHA1.Convert(dst, src, warn)
+}
@@ -0,0 +1,39 @@
+package pwnedlist
+
+import "testing"
+
+func TestOffsetOfAndIndexAt(t *testing.T) {
+	formats := []struct {
+		name       string
+		format     Format
+		recordSize int64
+	}{
+		{"SHA-1", SHA1, 42},
+		{"NTLM", NTLM, 34},
+		{"LF", Format{HashLen: 40, RecordSize: 41, LF: true}, 41},
+		// HASH:COUNT records padded to the longest count, which is how a
+		// list with counts has fixed-width records.
+		{"count", Format{HashLen: 40, RecordSize: SHA1.maxCountRecord()}, 63},
+	}
+	for _, tt := range formats {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, index := range []int64{0, 1, 2, 1000, 1<<31 - 1, 1 << 31, 1 << 32, 6e9} {
+				offset := tt.format.OffsetOf(index)
+				if want := index * tt.recordSize; offset != want {
+					t.Errorf("OffsetOf(%d) = %d, want %d", index, offset, want)
+				}
+				// Every byte of the record is part of it, up to the first
+				// one of the next record.
+				if got := tt.format.IndexAt(offset); got != index {
+					t.Errorf("IndexAt(%d), the first byte of record %d, = %d", offset, index, got)
+				}
+				if got := tt.format.IndexAt(offset + tt.recordSize - 1); got != index {
+					t.Errorf("IndexAt(%d), the last byte of record %d, = %d", offset+tt.recordSize-1, index, got)
+				}
+				if got := tt.format.IndexAt(offset + tt.recordSize); got != index+1 {
+					t.Errorf("IndexAt(%d), the first byte of record %d, = %d", offset+tt.recordSize, index+1, got)
+				}
+			}
+		})
+	}
+}
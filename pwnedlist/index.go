@@ -0,0 +1,186 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// indexMagic starts every file written by SparseIndex.WriteTo, followed by
+// indexVersion.
+var indexMagic = [8]byte{'P', 'W', 'N', 'I', 'N', 'D', 'E', 'X'}
+
+// indexVersion is the version of the index format, which changes whenever
+// the format does. Version 1 had no version in its header.
+const indexVersion = 2
+
+// indexHeaderSize is the size of the magic, the version, the hash length,
+// the record size and the numbers of records per sample and in the list.
+const indexHeaderSize = 36
+
+// SparseIndex has the hash of every Every-th record of a list with
+// fixed-width records. Kept in memory, it narrows a search down to a block of
+// that many records before the list is read, which saves the probes that
+// matter most on slow media: the first ones, which are far apart.
+type SparseIndex struct {
+	hashLen    int
+	recordSize int
+	every      int64
+	records    int64
+	// hashes has the sampled hashes one after the other.
+	hashes []byte
+}
+
+// BuildSparseIndex reads the list in r, and returns an index with the hash
+// of every every-th record, starting with the first one. It's an error for
+// the list not to be sorted.
+func (f Format) BuildSparseIndex(r io.Reader, every int64) (*SparseIndex, error) {
+	if every < 1 {
+		return nil, fmt.Errorf("can't sample every %d records", every)
+	}
+	x := &SparseIndex{hashLen: f.HashLen, recordSize: f.RecordSize, every: every}
+	rr := f.newRecordReader(r, false, 1<<20)
+	prev := make([]byte, f.HashLen)
+	for n := 1; ; n++ {
+		hash, _, err := rr.next()
+		if err == io.EOF {
+			return x, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, incompleteRecord(n, rr.partial)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := f.checkRecord(n, rr.buf); err != nil {
+			return nil, err
+		}
+		if n > 1 {
+			if err := checkOrder(n, prev, hash); err != nil {
+				return nil, err
+			}
+		}
+		copy(prev, hash)
+		if x.records%every == 0 {
+			x.hashes = append(x.hashes, hash...)
+		}
+		x.records++
+	}
+}
+
+// Records returns the number of records in the list the index was built for.
+func (x *SparseIndex) Records() int64 {
+	return x.records
+}
+
+// Every returns the number of records per sampled hash.
+func (x *SparseIndex) Every() int64 {
+	return x.every
+}
+
+func (x *SparseIndex) samples() int {
+	return len(x.hashes) / x.hashLen
+}
+
+func (x *SparseIndex) sample(i int) string {
+	return string(x.hashes[i*x.hashLen : (i+1)*x.hashLen])
+}
+
+// WriteTo writes the index to w, in a format ReadSparseIndex reads. The
+// integers in it are little-endian, like in all files written by this
+// package, so an index can be moved between machines.
+func (x *SparseIndex) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 0, indexHeaderSize)
+	header = append(header, indexMagic[:]...)
+	header = binary.LittleEndian.AppendUint32(header, indexVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(x.hashLen))
+	header = binary.LittleEndian.AppendUint32(header, uint32(x.recordSize))
+	header = binary.LittleEndian.AppendUint64(header, uint64(x.every))
+	header = binary.LittleEndian.AppendUint64(header, uint64(x.records))
+	bw.Write(header)
+	bw.Write(x.hashes)
+	n := int64(len(header) + len(x.hashes))
+	return n, bw.Flush()
+}
+
+// ReadSparseIndex reads an index written by SparseIndex.WriteTo. It's an
+// error for the index to be in another version of the format.
+func ReadSparseIndex(r io.Reader) (*SparseIndex, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	header := make([]byte, indexHeaderSize)
+	if _, err := io.ReadFull(br, header[:12]); err != nil {
+		return nil, fmt.Errorf("reading index header: %v", err)
+	}
+	if [8]byte(header[:8]) != indexMagic {
+		return nil, errors.New("not an index written by build-index")
+	}
+	if err := checkVersion("index", binary.LittleEndian.Uint32(header[8:]), indexVersion, "build-index"); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(br, header[12:]); err != nil {
+		return nil, fmt.Errorf("reading index header: %v", err)
+	}
+	x := &SparseIndex{
+		hashLen:    int(binary.LittleEndian.Uint32(header[12:])),
+		recordSize: int(binary.LittleEndian.Uint32(header[16:])),
+		every:      int64(binary.LittleEndian.Uint64(header[20:])),
+		records:    int64(binary.LittleEndian.Uint64(header[28:])),
+	}
+	if x.hashLen == 0 || x.recordSize <= x.hashLen || x.every < 1 || x.records < 0 {
+		return nil, errors.New("corrupt index header")
+	}
+	samples := (x.records + x.every - 1) / x.every
+	if samples > math.MaxInt/int64(x.hashLen) {
+		return nil, errors.New("corrupt index header")
+	}
+	x.hashes = make([]byte, samples*int64(x.hashLen))
+	if _, err := io.ReadFull(br, x.hashes); err != nil {
+		return nil, fmt.Errorf("reading index: %v", err)
+	}
+	return x, nil
+}
+
+// SearchIndexProbe is SearchProbe, only reading the block of records that
+// the index x says the hash would be in. The index has to be built for the
+// list in r.
+func (f Format) SearchIndexProbe(r io.ReaderAt, size int64, x *SparseIndex, hash string, probe ProbeFunc) (int64, error) {
+	if x.hashLen != f.HashLen || x.recordSize != f.RecordSize {
+		return -1, fmt.Errorf("index is for %d byte records of %d character hashes, not %d byte records of %d", x.recordSize, x.hashLen, f.RecordSize, f.HashLen)
+	}
+	recordSize := int64(f.RecordSize)
+	if size%recordSize != 0 {
+		return -1, badFormat("file size not a multiple of %d", recordSize)
+	}
+	if size/recordSize != x.records {
+		return -1, fmt.Errorf("index is for a list of %d records, not %d (rebuild it with build-index)", x.records, size/recordSize)
+	}
+	// Sample j is the first one that isn't less than the hash, so the first
+	// record that isn't is after sample j-1, and no later than sample j.
+	n := x.samples()
+	j := sort.Search(n, func(j int) bool { return x.sample(j) >= hash })
+	if j < n && x.sample(j) == hash {
+		return int64(j) * x.every, nil
+	}
+	if j == 0 {
+		return -1, ErrNotFound
+	}
+	start := int64(j-1) * x.every
+	end := min(int64(j)*x.every, x.records)
+	blockProbe := probe
+	if probe != nil {
+		blockProbe = func(n int, offset int64, hash []byte) {
+			probe(n, start*recordSize+offset, hash)
+		}
+	}
+	block := (end - start) * recordSize
+	i, err := f.SearchProbe(io.NewSectionReader(r, start*recordSize, block), block, hash, blockProbe)
+	if err != nil {
+		return -1, err
+	}
+	return start + i, nil
+}
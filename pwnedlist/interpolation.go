@@ -0,0 +1,82 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// SearchInterpolation is like SearchProbe, but runs an interpolation search:
+// instead of the middle of the range, it probes where the hash would be if the
+// hashes are spread evenly, which they are for SHA-1 and NTLM. That takes
+// about log(log(n)) probes instead of log(n). If a probe doesn't halve the
+// range, the next one bisects it, so it never takes more than twice as many
+// probes as a binary search.
+func (f Format) SearchInterpolation(r io.ReaderAt, size int64, hash string, probe ProbeFunc) (int64, error) {
+	if size%int64(f.RecordSize) != 0 {
+		return -1, badFormat("file size not a multiple of %d", f.RecordSize)
+	}
+	hashBytes := []byte(hash)
+	target, err := hashKey(hashBytes)
+	if err != nil {
+		return -1, err
+	}
+	buf := make([]byte, f.RecordSize)
+	recordSize := int64(f.RecordSize)
+	records := size / recordSize
+	// The records before low are < hash, the ones from high on are >= hash.
+	// lowKey and highKey bound the keys of the records in between.
+	low, high := int64(0), records
+	lowKey, highKey := uint64(0), uint64(math.MaxUint64)
+	bisect := false
+	for n := 1; low < high; n++ {
+		i := low + (high-low)/2
+		if !bisect && highKey > lowKey {
+			frac := float64(target-lowKey) / float64(highKey-lowKey)
+			i = low + int64(frac*float64(high-low-1))
+			if i < low {
+				i = low
+			} else if i >= high {
+				i = high - 1
+			}
+		}
+		if err := readFullAt(r, buf, i*recordSize); err != nil {
+			return -1, err
+		}
+		if probe != nil {
+			probe(n, i*recordSize, buf[:f.HashLen])
+		}
+		key, err := hashKey(buf[:f.HashLen])
+		if err != nil {
+			return -1, badRecord(i+1, "contained characters other than [0-9A-F]")
+		}
+		before := high - low
+		if bytes.Compare(buf[:f.HashLen], hashBytes) < 0 {
+			low, lowKey = i+1, key
+		} else {
+			high, highKey = i, key
+		}
+		bisect = high-low > before/2
+	}
+	if low == records {
+		return -1, ErrNotFound
+	}
+	if err := readFullAt(r, buf, low*recordSize); err != nil {
+		return -1, err
+	}
+	if bytes.Equal(buf[:f.HashLen], hashBytes) {
+		return low, nil
+	}
+	return -1, ErrNotFound
+}
+
+// hashKey returns the first 64 bits of hash, which is enough to interpolate
+// with.
+func hashKey(hash []byte) (uint64, error) {
+	if len(hash) < 16 || !IsHex(hash[:16]) {
+		return 0, fmt.Errorf("%q isn't a hexadecimal hash", hash)
+	}
+	return strconv.ParseUint(string(hash[:16]), 16, 64)
+}
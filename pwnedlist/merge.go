@@ -0,0 +1,128 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// mergeSource is one of the lists being merged, with its current record.
+type mergeSource struct {
+	index int
+	rr    *recordReader
+	n     int
+	hash  []byte
+	count uint64
+}
+
+// advance reads the next record of s, returning io.EOF at the end of the list.
+func (s *mergeSource) advance() error {
+	hash, count, err := s.rr.next()
+	if err == io.EOF {
+		return err
+	}
+	s.n++
+	if err == io.ErrUnexpectedEOF {
+		err = incompleteRecord(s.n, s.rr.partial)
+	}
+	if err != nil {
+		return fmt.Errorf("list %d: %w", s.index+1, err)
+	}
+	if !IsHex(hash) {
+		return fmt.Errorf("list %d: %w", s.index+1, badRecord(int64(s.n), "contained characters other than [0-9A-F]"))
+	}
+	if s.n > 1 {
+		if err := checkOrder(s.n, s.hash, hash); err != nil {
+			return fmt.Errorf("list %d: %w", s.index+1, err)
+		}
+	}
+	s.hash = append(s.hash[:0], hash...)
+	s.count = count
+	return nil
+}
+
+// mergeHeap orders the sources by their current hash, and by their position
+// in the arguments for equal hashes.
+type mergeHeap []*mergeSource
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].hash, h[j].hash); c != 0 {
+		return c < 0
+	}
+	return h[i].index < h[j].index
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(*mergeSource)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
+
+// Merge does a k-way merge of the sorted lists in srcs into one sorted list
+// in dst, reading every list once. A hash that's in more than one list, or
+// more than once in a list, is only written once, and for lists with counts
+// it keeps the count of the first list it's in. Merge returns the number of
+// written records and the number of dropped duplicates. A list that isn't
+// sorted is an error. Records of lists without counts are written as they
+// are, in the format of the lists.
+func (f Format) Merge(dst io.Writer, srcs []io.Reader, withCount bool) (written, dups int, err error) {
+	return f.merge(dst, srcs, withCount, true)
+}
+
+// merge is Merge, only dropping duplicates if dedupe is set.
+func (f Format) merge(dst io.Writer, srcs []io.Reader, withCount, dedupe bool) (written, dups int, err error) {
+	bw := bufio.NewWriterSize(dst, 1<<20)
+	h := make(mergeHeap, 0, len(srcs))
+	for i, src := range srcs {
+		s := &mergeSource{index: i, rr: f.newRecordReader(src, withCount, 1<<20)}
+		err := s.advance()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		h = append(h, s)
+	}
+	heap.Init(&h)
+	prev := make([]byte, 0, f.HashLen)
+	for len(h) > 0 {
+		s := h[0]
+		if dedupe && written > 0 && bytes.Equal(s.hash, prev) {
+			dups++
+		} else {
+			// Fixed-width records are written whole, keeping their padding
+			// and line ending.
+			var err error
+			if withCount {
+				bw.Write(s.hash)
+				bw.WriteByte(':')
+				bw.WriteString(strconv.FormatUint(s.count, 10))
+				_, err = bw.WriteString("\r\n")
+			} else {
+				_, err = bw.Write(s.rr.buf)
+			}
+			if err != nil {
+				return written, dups, err
+			}
+			prev = append(prev[:0], s.hash...)
+			written++
+		}
+		err := s.advance()
+		if err == io.EOF {
+			heap.Pop(&h)
+			continue
+		}
+		if err != nil {
+			return written, dups, err
+		}
+		heap.Fix(&h, 0)
+	}
+	return written, dups, bw.Flush()
+}
@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package pwnedlist
+
+import (
+	"errors"
+	"os"
+)
+
+const mmapSupported = false
+
+func mmap(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("mmap is not supported on this platform")
+}
+
+func munmap(data []byte) error {
+	return nil
+}
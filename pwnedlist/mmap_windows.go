@@ -0,0 +1,31 @@
+//go:build windows
+
+package pwnedlist
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const mmapSupported = true
+
+func mmap(f *os.File, size int64) ([]byte, error) {
+	h, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READONLY, uint32(size>>32), uint32(size), nil)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	// The view keeps its own reference to the mapping object.
+	_ = syscall.CloseHandle(h)
+	if err != nil {
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	// Convert through a pointer to addr to keep go vet's unsafe.Pointer
+	// check happy; the view isn't managed by the Go heap.
+	return unsafe.Slice(*(**byte)(unsafe.Pointer(&addr)), size), nil
+}
+
+func munmap(data []byte) error {
+	return syscall.UnmapViewOfFile(uintptr(unsafe.Pointer(&data[0])))
+}
@@ -0,0 +1,84 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Neighbor is a record next to the place of a hash in a list.
+type Neighbor struct {
+	// Offset is the byte offset of the record.
+	Offset int64
+	Hash   string
+	// Count is 0 unless the list has counts.
+	Count uint64
+}
+
+// Nearest returns the records on either side of the place hash has, or would
+// have, in the list in r, which is size bytes long: the last one with a lower
+// hash and the first one with a higher hash. below is nil if hash sorts before
+// every record, and above is nil if it sorts after every record.
+func (f Format) Nearest(r io.ReaderAt, size int64, hash string, withCount bool) (below, above *Neighbor, err error) {
+	hashBytes := []byte(hash)
+	var start, prev int64
+	if withCount {
+		buf := make([]byte, f.maxCountRecord())
+		start, err = f.lowerBoundCount(r, size, hashBytes, buf, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if start > 0 {
+			// The byte before start ends the previous record.
+			prev, err = recordStart(r, start-1, buf)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	} else {
+		if size%int64(f.RecordSize) != 0 {
+			return nil, nil, badFormat("file size not a multiple of %d", f.RecordSize)
+		}
+		i, err := f.lowerBound(r, size, hashBytes, make([]byte, f.RecordSize), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		start = i * int64(f.RecordSize)
+		prev = start - int64(f.RecordSize)
+	}
+	if start > 0 {
+		h, count, err := f.RecordAt(r, size, prev, withCount)
+		if err != nil {
+			return nil, nil, err
+		}
+		below = &Neighbor{Offset: prev, Hash: string(h), Count: count}
+	}
+	// The records from start on are the hash itself as long as they match.
+	buf := make([]byte, f.maxCountRecord())
+	for offset := start; offset < size; {
+		var h []byte
+		var count uint64
+		next := offset + int64(f.RecordSize)
+		if withCount {
+			record, err := f.readRecordAt(r, offset, buf)
+			if err != nil {
+				return nil, nil, err
+			}
+			h, count, err = f.parseCountRecord(record)
+			if err != nil {
+				return nil, nil, fmt.Errorf("at byte offset %d: %w", offset, err)
+			}
+			next = offset + int64(len(record))
+		} else {
+			h, _, err = f.RecordAt(r, size, offset, false)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if !bytes.Equal(h, hashBytes) {
+			return below, &Neighbor{Offset: offset, Hash: string(h), Count: count}, nil
+		}
+		offset = next
+	}
+	return below, nil, nil
+}
@@ -0,0 +1,196 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// chunkRecords is the number of records a worker of CheckParallel checks
+// in one go.
+const chunkRecords = 1 << 20
+
+// CheckParallel is like Check, but checks chunks of a list with fixed-width
+// records concurrently using opts.Workers goroutines. Errors report the record
+// number within the whole list, and only the first error in the list is
+// returned, with a summary that only has the number of duplicates found.
+func CheckParallel(r io.ReaderAt, size int64, opts CheckOptions) (CheckSummary, error) {
+	if opts.SkipBOM {
+		r, size = SkipBOM(r, size)
+	}
+	recordSize := int64(opts.format().RecordSize)
+	records := int(size / recordSize)
+
+	var mu sync.Mutex
+	errN := -1
+	var firstErr error
+	failed := func(n int, err error) {
+		mu.Lock()
+		if errN == -1 || n < errN {
+			errN, firstErr = n, err
+		}
+		mu.Unlock()
+	}
+	failedBefore := func(n int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return errN != -1 && errN <= n
+	}
+	if size%recordSize != 0 {
+		failed(records+1, incompleteRecord(records+1, int(size%recordSize)))
+	}
+
+	var checked, dups, outOfOrder atomic.Int64
+	var histogram []int
+	if opts.Histogram {
+		histogram = make([]int, 16)
+	}
+	chunks := make(chan int)
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				end := start + chunkRecords
+				if end > records {
+					end = records
+				}
+				n, hist, err := checkChunk(r, start, end, opts, &checked, &dups, &outOfOrder)
+				if err != nil {
+					failed(n, err)
+				}
+				if hist != nil {
+					mu.Lock()
+					for i, c := range hist {
+						histogram[i] += c
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	go func() {
+		for start := 0; start < records && !failedBefore(start+1); start += chunkRecords {
+			chunks <- start
+		}
+		close(chunks)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	if opts.Progress != nil {
+		t := time.NewTicker(opts.progressInterval())
+		defer t.Stop()
+	loop:
+		for {
+			select {
+			case <-t.C:
+				opts.Progress(checked.Load())
+			case <-done:
+				break loop
+			}
+		}
+	} else {
+		<-done
+	}
+	sum := CheckSummary{Duplicates: int(dups.Load()), OutOfOrder: int(outOfOrder.Load()), Histogram: histogram}
+	if firstErr != nil {
+		return sum, firstErr
+	}
+	if records > 0 {
+		f := opts.format()
+		buf := make([]byte, f.HashLen)
+		if err := readFullAt(r, buf, 0); err != nil {
+			return sum, err
+		}
+		sum.First = string(buf)
+		if err := readFullAt(r, buf, int64(records-1)*recordSize); err != nil {
+			return sum, err
+		}
+		sum.Last = string(buf)
+	}
+	sum.Records = records
+	if sum.Duplicates > 0 && opts.Warn == nil {
+		return sum, fmt.Errorf("%d duplicate hashes found", sum.Duplicates)
+	}
+	return sum, nil
+}
+
+// checkChunk checks records start up to end of r, adding the number of
+// checked records, duplicates and, with opts.Warn, hashes out of order to
+// checked, dups and outOfOrder along the way. It
+// returns the histogram of the chunk if opts asks for one, and on error the
+// number of the failing record. The first record is compared to the last
+// record of the preceding chunk.
+func checkChunk(r io.ReaderAt, start, end int, opts CheckOptions, checked, dups, outOfOrder *atomic.Int64) (int, []int, error) {
+	f := opts.format()
+	recordSize := int64(f.RecordSize)
+	buf := make([]byte, f.RecordSize)
+	hash := buf[:f.HashLen]
+	var prev []byte
+	compare := opts.CheckOrder || opts.CheckDuplicates
+	if compare && start > 0 {
+		if err := readFullAt(r, buf, int64(start-1)*recordSize); err != nil {
+			return start, nil, err
+		}
+		prev = append(prev, hash...)
+	}
+	var hist []int
+	if opts.Histogram {
+		hist = make([]int, 16)
+	}
+	br := bufio.NewReaderSize(io.NewSectionReader(r, int64(start)*recordSize, int64(end-start)*recordSize), opts.BufferSize)
+	if start == 0 {
+		if err := f.checkStart(br); err != nil {
+			return 1, nil, err
+		}
+	}
+	pending := int64(0)
+	for i := start; i < end; i++ {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return i + 1, nil, err
+		}
+		if err := f.checkRecord(i+1, buf); err != nil {
+			return i + 1, nil, err
+		}
+		if compare {
+			if prev != nil && opts.CheckOrder {
+				if err := checkOrder(i+1, prev, hash); err != nil && opts.Warn != nil {
+					outOfOrder.Add(1)
+					opts.Warn(err)
+				} else if err != nil {
+					return i + 1, nil, err
+				}
+			}
+			if prev != nil && opts.CheckDuplicates {
+				if err := checkDuplicate(i+1, prev, hash); err != nil {
+					dups.Add(1)
+					if opts.Warn != nil {
+						opts.Warn(err)
+					}
+				}
+			}
+			prev = append(prev[:0], hash...)
+		}
+		if hist != nil {
+			hist[nibble(hash[0])]++
+		}
+		if pending++; pending == 4096 {
+			checked.Add(pending)
+			pending = 0
+		}
+	}
+	checked.Add(pending)
+	return 0, hist, nil
+}
@@ -0,0 +1,62 @@
+// Package pwnedlist searches and checks the Pwned Password list.
+//
+// The list is a sorted file of SHA-1 hashes in uppercase hexadecimal
+// notation, one per line and ending with CR + LF. Every record is 42 bytes,
+// which makes it possible to binary search the list. The official download
+// also comes in a HASH:COUNT format, with variable length records, and there
+// is an NTLM variant with 32 character hashes.
+package pwnedlist
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// HashPassword returns the SHA-1 hash of password, in the notation used by
+// the list.
+func HashPassword(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// HashPasswordNTLM returns the NTLM hash of password, which is the MD4 hash
+// of its UTF-16LE encoding, in the notation used by the NTLM list.
+func HashPasswordNTLM(password string) string {
+	h := md4.New()
+	for _, c := range utf16.Encode([]rune(password)) {
+		h.Write([]byte{byte(c), byte(c >> 8)})
+	}
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// IsHex reports whether b consists of uppercase hexadecimal characters only.
+func IsHex(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case
+			'0', '1', '2', '3', '4', '5', '6', '7',
+			'8', '9', 'A', 'B', 'C', 'D', 'E', 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// readFullAt reads len(buf) bytes at off. Unlike a plain ReadAt, reading a
+// full buffer that ends at the end of r isn't an error.
+func readFullAt(r io.ReaderAt, buf []byte, off int64) error {
+	n, err := r.ReadAt(buf, off)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
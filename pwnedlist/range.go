@@ -0,0 +1,46 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"io"
+)
+
+// Range calls fn, in order, for every record in the list in r whose hash
+// starts with prefix. The count passed to fn is 0 unless withCount is set.
+// The hash passed to fn is only valid during the call.
+func (f Format) Range(r io.ReaderAt, size int64, prefix string, withCount bool, fn func(hash []byte, count uint64) error) error {
+	prefixBytes := []byte(prefix)
+	var start int64
+	if withCount {
+		var err error
+		start, err = f.lowerBoundCount(r, size, prefixBytes, make([]byte, f.maxCountRecord()), nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		if size%int64(f.RecordSize) != 0 {
+			return badFormat("file size not a multiple of %d", f.RecordSize)
+		}
+		i, err := f.lowerBound(r, size, prefixBytes, make([]byte, f.RecordSize), nil)
+		if err != nil {
+			return err
+		}
+		start = i * int64(f.RecordSize)
+	}
+	rr := f.newRecordReader(io.NewSectionReader(r, start, size-start), withCount, 1<<12)
+	for {
+		hash, count, err := rr.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(hash, prefixBytes) {
+			return nil
+		}
+		if err := fn(hash, count); err != nil {
+			return err
+		}
+	}
+}
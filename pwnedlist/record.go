@@ -0,0 +1,110 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// recordReader reads the records of a list one by one.
+type recordReader struct {
+	r         *bufio.Reader
+	format    Format
+	withCount bool
+	buf       []byte
+	// partial is the number of bytes of the last record, if the list ended
+	// in the middle of it.
+	partial int
+}
+
+// incompleteRecord is the error for a list that ends after size bytes of
+// record n.
+func incompleteRecord(n, size int) error {
+	return badRecord(int64(n), "is incomplete, the file ends after %d bytes of it", size)
+}
+
+func (f Format) newRecordReader(r io.Reader, withCount bool, bufferSize int) *recordReader {
+	return &recordReader{
+		r:         bufio.NewReaderSize(r, bufferSize),
+		format:    f,
+		withCount: withCount,
+		buf:       make([]byte, f.RecordSize),
+	}
+}
+
+// next returns the hash and count of the next record, or io.EOF at the end of
+// the list. The count is 0 for lists without counts. The hash is only valid
+// until the next call to next.
+func (rr *recordReader) next() ([]byte, uint64, error) {
+	if rr.withCount {
+		record, err := rr.r.ReadSlice('\n')
+		if err == io.EOF && len(record) == 0 {
+			return nil, 0, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		return rr.format.parseCountRecord(record)
+	}
+	if n, err := io.ReadFull(rr.r, rr.buf); err != nil {
+		rr.partial = n
+		return nil, 0, err
+	}
+	return rr.buf[:rr.format.HashLen], 0, nil
+}
+
+// SkipBOM returns the part of the size bytes in r after the UTF-8 BOM they
+// start with, if they do, and its size. Offsets in the returned reader are
+// len(UTF8BOM) less than in r then.
+func SkipBOM(r io.ReaderAt, size int64) (io.ReaderAt, int64) {
+	b := make([]byte, len(UTF8BOM))
+	if n, _ := r.ReadAt(b, 0); n == len(b) && string(b) == UTF8BOM {
+		return io.NewSectionReader(r, int64(len(b)), size-int64(len(b))), size - int64(len(b))
+	}
+	return r, size
+}
+
+// RecordAt reads the record starting at byte offset in a list of size bytes,
+// and returns its hash, and its count if withCount is set. For fixed-width
+// lists offset has to be a multiple of RecordSize.
+func (f Format) RecordAt(r io.ReaderAt, size, offset int64, withCount bool) ([]byte, uint64, error) {
+	if offset < 0 || offset >= size {
+		return nil, 0, fmt.Errorf("byte offset %d is outside the list of %d bytes", offset, size)
+	}
+	if withCount {
+		if offset > 0 {
+			prev := make([]byte, 1)
+			if _, err := r.ReadAt(prev, offset-1); err != nil {
+				return nil, 0, err
+			}
+			if prev[0] != '\n' {
+				return nil, 0, fmt.Errorf("byte offset %d isn't at the start of a record", offset)
+			}
+		}
+		record, err := f.readRecordAt(r, offset, make([]byte, f.maxCountRecord()))
+		if err != nil {
+			return nil, 0, err
+		}
+		hash, count, err := f.parseCountRecord(record)
+		if err != nil {
+			return nil, 0, fmt.Errorf("at byte offset %d: %w", offset, err)
+		}
+		return hash, count, nil
+	}
+	if offset%int64(f.RecordSize) != 0 {
+		return nil, 0, fmt.Errorf("byte offset %d isn't at the start of a record of %d bytes", offset, f.RecordSize)
+	}
+	n := int(f.IndexAt(offset)) + 1
+	record := make([]byte, f.RecordSize)
+	read, err := r.ReadAt(record, offset)
+	if err == io.EOF && read < len(record) {
+		return nil, 0, incompleteRecord(n, read)
+	}
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	if err := f.checkRecord(n, record); err != nil {
+		return nil, 0, err
+	}
+	return record[:f.HashLen], 0, nil
+}
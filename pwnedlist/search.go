@@ -0,0 +1,89 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"io"
+)
+
+// A ProbeFunc is called for every probe of a binary search, with the number
+// of the probe, starting at 1, the byte offset of the record it read and the
+// hash of that record. The hash is only valid until the function returns.
+type ProbeFunc func(n int, offset int64, hash []byte)
+
+// Search runs a binary search for hash in the list in r, which is size bytes
+// long. It returns the index of the matching record, or -1 and ErrNotFound if
+// the hash isn't in the list.
+func (f Format) Search(r io.ReaderAt, size int64, hash string) (int64, error) {
+	return f.SearchProbe(r, size, hash, nil)
+}
+
+// SearchProbe is Search, calling probe for every probe if it isn't nil.
+func (f Format) SearchProbe(r io.ReaderAt, size int64, hash string, probe ProbeFunc) (int64, error) {
+	if size%int64(f.RecordSize) != 0 {
+		return -1, badFormat("file size not a multiple of %d", f.RecordSize)
+	}
+	hashBytes := []byte(hash)
+	buf := make([]byte, f.RecordSize)
+	i, err := f.lowerBound(r, size, hashBytes, buf, probe)
+	if err != nil {
+		return -1, err
+	}
+	if i*int64(f.RecordSize) == size {
+		return -1, ErrNotFound
+	}
+	err = readFullAt(r, buf, i*int64(f.RecordSize))
+	if err != nil {
+		return -1, err
+	}
+	if bytes.Equal(buf[:f.HashLen], hashBytes) {
+		return i, nil
+	}
+	return -1, ErrNotFound
+}
+
+// lowerBound returns the index of the first record with a hash >= hash. A
+// failed read aborts the search, the bounds are meaningless after it. The
+// indexes are int64, so lists of more than 2^31 records can be searched on
+// 32-bit platforms too.
+func (f Format) lowerBound(r io.ReaderAt, size int64, hash []byte, buf []byte, probe ProbeFunc) (int64, error) {
+	recordSize := int64(f.RecordSize)
+	low, high := int64(0), size/recordSize
+	for n := 1; low < high; n++ {
+		i := low + (high-low)/2
+		if err := readFullAt(r, buf, i*recordSize); err != nil {
+			return -1, err
+		}
+		if probe != nil {
+			probe(n, i*recordSize, buf[:f.HashLen])
+		}
+		if bytes.Compare(buf[:f.HashLen], hash) < 0 {
+			low = i + 1
+		} else {
+			high = i
+		}
+	}
+	return low, nil
+}
+
+// SearchStream does a linear scan for hash, for readers that can't be seeked
+// (like stdin). It stops as soon as it passes the place where the hash would
+// be in a sorted list, and returns ErrNotFound then.
+func (f Format) SearchStream(r io.Reader, hash string) (int64, error) {
+	hashBytes := []byte(hash)
+	buf := make([]byte, f.RecordSize)
+	for i := int64(0); ; i++ {
+		_, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return -1, ErrNotFound
+		}
+		if err != nil {
+			return -1, err
+		}
+		switch bytes.Compare(buf[:f.HashLen], hashBytes) {
+		case 0:
+			return i, nil
+		case 1:
+			return -1, ErrNotFound
+		}
+	}
+}
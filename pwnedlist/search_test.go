@@ -0,0 +1,302 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testHashes returns n sorted, distinct hashes of hashLen characters.
+func testHashes(n, hashLen int) []string {
+	hashes := make([]string, n)
+	for i := range hashes {
+		hashes[i] = fmt.Sprintf("%0*X", hashLen, uint64(i)*0x1F3D5B79)
+	}
+	return hashes
+}
+
+// writeTestList writes hashes as a list in f, with the count of the ith hash
+// being i+1 if withCount is set, to a file in a temporary directory, and
+// returns its name.
+func writeTestList(t *testing.T, f Format, hashes []string, withCount bool) string {
+	t.Helper()
+	var b strings.Builder
+	for i, hash := range hashes {
+		if withCount {
+			fmt.Fprintf(&b, "%s:%d\r\n", hash, i+1)
+			continue
+		}
+		end := f.LineEnding()
+		b.WriteString(hash + strings.Repeat(" ", f.RecordSize-f.HashLen-len(end)) + end)
+	}
+	name := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(name, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// recordAtOffset seeks to offset in the file name and returns the line that
+// starts there.
+func recordAtOffset(t *testing.T, name string, offset int64) string {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return line
+}
+
+func TestSearchOffsetIsRecordStart(t *testing.T) {
+	formats := []struct {
+		name   string
+		format Format
+	}{
+		{"SHA-1", SHA1},
+		{"NTLM", NTLM},
+		{"LF", Format{HashLen: 40, RecordSize: 41, LF: true}},
+		{"padded", Format{HashLen: 40, RecordSize: 48}},
+	}
+	for _, tt := range formats {
+		t.Run(tt.name, func(t *testing.T) {
+			hashes := testHashes(1000, tt.format.HashLen)
+			name := writeTestList(t, tt.format, hashes, false)
+			file, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			fi, err := file.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, hash := range []string{hashes[0], hashes[1], hashes[500], hashes[999]} {
+				i, err := tt.format.Search(file, fi.Size(), hash)
+				if err != nil {
+					t.Fatalf("Search(%s): %v", hash, err)
+				}
+				line := recordAtOffset(t, name, tt.format.OffsetOf(i))
+				if !strings.HasPrefix(line, hash) || len(line) != tt.format.RecordSize {
+					t.Errorf("record at offset %d of %s is %q", tt.format.OffsetOf(i), hash, line)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchCountOffsetIsRecordStart(t *testing.T) {
+	hashes := testHashes(1000, SHA1.HashLen)
+	name := writeTestList(t, SHA1, hashes, true)
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The counts go from 1 to 4 digits, so the records aren't all as long.
+	for _, i := range []int{0, 1, 8, 9, 98, 99, 500, 999} {
+		offset, count, err := SHA1.SearchCount(file, fi.Size(), hashes[i])
+		if err != nil {
+			t.Fatalf("SearchCount(%s): %v", hashes[i], err)
+		}
+		if count != uint64(i+1) {
+			t.Errorf("count of hash %d is %d, want %d", i, count, i+1)
+		}
+		want := fmt.Sprintf("%s:%d\r\n", hashes[i], i+1)
+		if line := recordAtOffset(t, name, offset); line != want {
+			t.Errorf("record at offset %d is %q, want %q", offset, line, want)
+		}
+	}
+}
+
+// failingReaderAt passes reads on to r until it has done ok of them, and
+// fails every read after that with err.
+type failingReaderAt struct {
+	r   io.ReaderAt
+	ok  int
+	err error
+}
+
+func (f *failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if f.ok == 0 {
+		return 0, f.err
+	}
+	f.ok--
+	return f.r.ReadAt(p, off)
+}
+
+func TestSearchReadError(t *testing.T) {
+	hashes := testHashes(1000, SHA1.HashLen)
+	errRead := errors.New("read failed")
+	for _, withCount := range []bool{false, true} {
+		name := writeTestList(t, SHA1, hashes, withCount)
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		fi, err := file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The searches take about 10 probes, so failing after 0 to 4 reads
+		// fails in the middle of the search.
+		for ok := 0; ok < 5; ok++ {
+			for _, hash := range []string{hashes[0], hashes[777], "F" + hashes[0][1:]} {
+				r := &failingReaderAt{file, ok, errRead}
+				if withCount {
+					_, _, err = SHA1.SearchCount(r, fi.Size(), hash)
+				} else {
+					_, err = SHA1.Search(r, fi.Size(), hash)
+				}
+				if !errors.Is(err, errRead) {
+					t.Errorf("withCount %v, failing after %d reads: search for %s returned %v, want the read error", withCount, ok, hash, err)
+				}
+			}
+		}
+	}
+}
+
+// syntheticList is a list of SHA-1 records that are made up as they're read,
+// for lists too big to write out. Record i has the hash i*stride, in the
+// first 16 of its 40 characters.
+type syntheticList struct {
+	records, stride uint64
+}
+
+func (l syntheticList) hash(i uint64) string {
+	return fmt.Sprintf("%016X%024X", i*l.stride, 0)
+}
+
+func (l syntheticList) size() int64 {
+	return int64(l.records) * int64(SHA1.RecordSize)
+}
+
+func (l syntheticList) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) && off < l.size() {
+		i := uint64(off) / uint64(SHA1.RecordSize)
+		record := l.hash(i) + "\r\n"
+		c := copy(p[n:], record[off%int64(SHA1.RecordSize):])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestSearchMoreThan2To31Records(t *testing.T) {
+	records := uint64(5) << 31
+	l := syntheticList{records, (1 << 63) / records * 2}
+	searches := []struct {
+		name   string
+		search func(r io.ReaderAt, size int64, hash string) (int64, error)
+	}{
+		{"binary", SHA1.Search},
+		{"interpolation", func(r io.ReaderAt, size int64, hash string) (int64, error) {
+			return SHA1.SearchInterpolation(r, size, hash, nil)
+		}},
+	}
+	for _, s := range searches {
+		t.Run(s.name, func(t *testing.T) {
+			for _, i := range []uint64{0, 1<<31 - 1, 1 << 31, 1 << 32, 3<<31 + 12345, records - 1} {
+				got, err := s.search(l, l.size(), l.hash(i))
+				if err != nil || got != int64(i) {
+					t.Errorf("search for record %d = %d, %v", i, got, err)
+				}
+			}
+			// The stride is even, so an odd hash isn't in the list.
+			missing := fmt.Sprintf("%016X%024X", (1<<32+7)*l.stride+1, 0)
+			if got, err := s.search(l, l.size(), missing); got != -1 || !errors.Is(err, ErrNotFound) {
+				t.Errorf("search for a missing hash = %d, %v, want -1, ErrNotFound", got, err)
+			}
+		})
+	}
+}
+
+// TestLowerBoundMatchesSortSearch checks that the bisection of lowerBound
+// finds the first record >= the hash, which is what sort.Search found before
+// it, including in lists with duplicates.
+func TestLowerBoundMatchesSortSearch(t *testing.T) {
+	h := testHashes(5, SHA1.HashLen)
+	lists := []struct {
+		name   string
+		hashes []string
+	}{
+		{"empty", nil},
+		{"one", h[1:2]},
+		{"two", h[1:3]},
+		{"odd", h[:5]},
+		{"even", h[:4]},
+		{"duplicates", []string{h[0], h[1], h[1], h[1], h[3]}},
+		{"all the same", []string{h[2], h[2], h[2]}},
+	}
+	// Every hash is looked for, with hashes just below and above it.
+	var targets []string
+	for _, hash := range h {
+		targets = append(targets, hash[:len(hash)-1]+"0", hash, hash[:len(hash)-1]+"F")
+	}
+	targets = append(targets, strings.Repeat("0", SHA1.HashLen), strings.Repeat("F", SHA1.HashLen))
+	buf := make([]byte, SHA1.maxCountRecord())
+	for _, l := range lists {
+		var list, countList strings.Builder
+		// offsets has the byte offset of every record with counts, and the
+		// size of the list at the end.
+		var offsets []int64
+		for i, hash := range l.hashes {
+			list.WriteString(hash + "\r\n")
+			offsets = append(offsets, int64(countList.Len()))
+			fmt.Fprintf(&countList, "%s:%d\r\n", hash, 1<<(4*i))
+		}
+		offsets = append(offsets, int64(countList.Len()))
+		r := strings.NewReader(list.String())
+		cr := strings.NewReader(countList.String())
+		for _, target := range targets {
+			want := sort.Search(len(l.hashes), func(i int) bool { return l.hashes[i] >= target })
+			got, err := SHA1.lowerBound(r, r.Size(), []byte(target), buf[:SHA1.RecordSize], nil)
+			if err != nil || got != int64(want) {
+				t.Errorf("%s: lowerBound(%s) = %d, %v, sort.Search found %d", l.name, target, got, err, want)
+			}
+			offset, err := SHA1.lowerBoundCount(cr, cr.Size(), []byte(target), buf, nil)
+			if err != nil || offset != offsets[want] {
+				t.Errorf("%s: lowerBoundCount(%s) = %d, %v, want offset %d of record %d", l.name, target, offset, err, offsets[want], want)
+			}
+		}
+	}
+}
+
+func TestSearchNotFound(t *testing.T) {
+	hashes := testHashes(100, SHA1.HashLen)
+	name := writeTestList(t, SHA1, hashes[1:], false)
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := SHA1.Search(file, fi.Size(), hashes[0]); i != -1 || !errors.Is(err, ErrNotFound) {
+		t.Errorf("Search of a missing hash = %d, %v, want -1, ErrNotFound", i, err)
+	}
+}
@@ -0,0 +1,130 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// Searcher keeps a list with fixed-width records open for many lookups. It is
+// safe for concurrent use by multiple goroutines.
+type Searcher struct {
+	format  Format
+	f       *os.File
+	data    []byte
+	r       io.ReaderAt
+	size    int64
+	records int64
+}
+
+// NewSearcher opens the SHA-1 list at path, memory-mapping it if the platform
+// supports it.
+func NewSearcher(path string) (*Searcher, error) {
+	return SHA1.OpenSearcher(path, true)
+}
+
+// OpenSearcher opens the list at path, only memory-mapping it if useMmap is
+// set.
+func (format Format) OpenSearcher(path string, useMmap bool) (*Searcher, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	recordSize := int64(format.RecordSize)
+	if fi.Size()%recordSize != 0 {
+		_ = f.Close()
+		return nil, badFormat("file size not a multiple of %d", recordSize)
+	}
+	s := &Searcher{
+		format:  format,
+		f:       f,
+		r:       f,
+		size:    fi.Size(),
+		records: fi.Size() / recordSize,
+	}
+	// A list bigger than the address space, on 32-bit platforms, is read
+	// with ReadAt instead.
+	if useMmap && mmapSupported && s.size > 0 && s.size <= math.MaxInt {
+		s.data, err = mmap(f, s.size)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		s.r = bytes.NewReader(s.data)
+	}
+	return s, nil
+}
+
+// Records returns the number of records in the list.
+func (s *Searcher) Records() int64 {
+	return s.records
+}
+
+// Lookup searches for hash, and returns the byte offset of its record if it
+// was found. A miss is reported with found, not with ErrNotFound.
+func (s *Searcher) Lookup(hash string) (offset int64, found bool, err error) {
+	return s.LookupProbe(hash, nil)
+}
+
+// LookupProbe is Lookup, calling probe for every probe if it isn't nil.
+func (s *Searcher) LookupProbe(hash string, probe ProbeFunc) (offset int64, found bool, err error) {
+	i, err := s.format.SearchProbe(s.r, s.size, hash, probe)
+	if errors.Is(err, ErrNotFound) {
+		return -1, false, nil
+	}
+	if err != nil {
+		return -1, false, err
+	}
+	return s.format.OffsetOf(i), true, nil
+}
+
+// LookupInterpolation is LookupProbe, using an interpolation search.
+func (s *Searcher) LookupInterpolation(hash string, probe ProbeFunc) (offset int64, found bool, err error) {
+	i, err := s.format.SearchInterpolation(s.r, s.size, hash, probe)
+	if errors.Is(err, ErrNotFound) {
+		return -1, false, nil
+	}
+	if err != nil {
+		return -1, false, err
+	}
+	return s.format.OffsetOf(i), true, nil
+}
+
+// Each calls fn with the 0-based index and the hash of every record, in
+// order, stopping at the first error fn returns, which Each returns too. It
+// reads the whole file sequentially, so it takes as long as reading the file
+// does, unlike a lookup. The hash is only valid until fn returns.
+func (s *Searcher) Each(fn func(index int64, hash []byte) error) error {
+	rr := s.format.newRecordReader(io.NewSectionReader(s.r, 0, s.size), false, 1<<20)
+	for i := int64(0); i < s.records; i++ {
+		hash, _, err := rr.next()
+		if err != nil {
+			return err
+		}
+		if err := fn(i, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close unmaps and closes the list. The file is closed even if unmapping it
+// fails.
+func (s *Searcher) Close() error {
+	var err error
+	if s.data != nil {
+		err = munmap(s.data)
+		s.data = nil
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package pwnedlist
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSearcherConcurrentLookups is meant to be run with -race, which reports
+// probes of one goroutine that share a buffer with another.
+func TestSearcherConcurrentLookups(t *testing.T) {
+	hashes := testHashes(10000, SHA1.HashLen)
+	// Every other hash is left out, so that half of the lookups miss.
+	var list []string
+	for i := 0; i < len(hashes); i += 2 {
+		list = append(list, hashes[i])
+	}
+	name := writeTestList(t, SHA1, list, false)
+	for _, useMmap := range []bool{false, true} {
+		s, err := SHA1.OpenSearcher(name, useMmap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for g := 0; g < 16; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := g; i < len(hashes); i += 7 {
+					offset, found, err := s.Lookup(hashes[i])
+					if err != nil {
+						t.Errorf("Lookup(%s): %v", hashes[i], err)
+						return
+					}
+					if found != (i%2 == 0) {
+						t.Errorf("Lookup(%s) found = %v", hashes[i], found)
+					} else if found && offset != SHA1.OffsetOf(int64(i/2)) {
+						t.Errorf("Lookup(%s) = offset %d, want %d", hashes[i], offset, SHA1.OffsetOf(int64(i/2)))
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package pwnedlist
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+)
+
+// records sorts the fixed-width records in a buffer by hash.
+type records struct {
+	buf  []byte
+	size int
+	tmp  []byte
+}
+
+func (r records) Len() int { return len(r.buf) / r.size }
+func (r records) Less(i, j int) bool {
+	return bytes.Compare(r.buf[i*r.size:(i+1)*r.size], r.buf[j*r.size:(j+1)*r.size]) < 0
+}
+func (r records) Swap(i, j int) {
+	a, b := r.buf[i*r.size:(i+1)*r.size], r.buf[j*r.size:(j+1)*r.size]
+	copy(r.tmp, a)
+	copy(a, b)
+	copy(b, r.tmp)
+}
+
+// Sort writes the fixed-width records of src to dst in sorted order, keeping
+// duplicates. It sorts chunks of at most memory bytes at a time, and if the
+// list doesn't fit in one chunk it spills the sorted chunks to temporary files
+// in tmpDir, which are merged afterwards. It returns the number of records.
+func (f Format) Sort(dst io.Writer, src io.Reader, memory int, tmpDir string) (int, error) {
+	chunk := make([]byte, max(memory/f.RecordSize, 1)*f.RecordSize)
+	var spilled []*os.File
+	defer func() {
+		for _, t := range spilled {
+			_ = t.Close()
+			_ = os.Remove(t.Name())
+		}
+	}()
+	n := 0
+	for {
+		read, err := io.ReadFull(src, chunk)
+		if err == io.ErrUnexpectedEOF && read%f.RecordSize != 0 {
+			return n, incompleteRecord(n+read/f.RecordSize+1, read%f.RecordSize)
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return n, err
+		}
+		buf := chunk[:read]
+		for i := 0; i < read; i += f.RecordSize {
+			if err := f.checkRecord(n+i/f.RecordSize+1, buf[i:i+f.RecordSize]); err != nil {
+				return n, err
+			}
+		}
+		n += read / f.RecordSize
+		sort.Sort(records{buf, f.RecordSize, make([]byte, f.RecordSize)})
+		last := err != nil
+		if last && len(spilled) == 0 {
+			_, err := dst.Write(buf)
+			return n, err
+		}
+		if read > 0 {
+			t, err := os.CreateTemp(tmpDir, "pwned-sort-")
+			if err != nil {
+				return n, err
+			}
+			spilled = append(spilled, t)
+			if _, err := t.Write(buf); err != nil {
+				return n, err
+			}
+		}
+		if last {
+			break
+		}
+	}
+	srcs := make([]io.Reader, len(spilled))
+	for i, t := range spilled {
+		if _, err := t.Seek(0, io.SeekStart); err != nil {
+			return n, err
+		}
+		srcs[i] = t
+	}
+	_, _, err := f.merge(dst, srcs, false, false)
+	return n, err
+}
@@ -0,0 +1,69 @@
+package pwnedlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Stats summarizes a list.
+type Stats struct {
+	// Bytes is the size of the list. For fixed-width lists it's a multiple
+	// of the record size, unless the list was cut off.
+	Bytes int64
+	// Records is the number of complete records.
+	Records int64
+	// First and Last are the hashes of the first and last record.
+	First, Last string
+	// TotalCount and MaxCount are the sum and the maximum of the counts, for
+	// lists with counts.
+	TotalCount, MaxCount uint64
+}
+
+// Summarize reads the whole list in r and summarizes it. Only HASH:COUNT
+// records are parsed, fixed-width records aren't checked at all.
+func (f Format) Summarize(r io.Reader, withCount bool) (Stats, error) {
+	var s Stats
+	br := bufio.NewReaderSize(r, 1<<20)
+	buf := make([]byte, f.RecordSize)
+	var last []byte
+	for {
+		var hash []byte
+		if withCount {
+			record, err := br.ReadSlice('\n')
+			s.Bytes += int64(len(record))
+			if err == io.EOF && len(record) == 0 {
+				break
+			}
+			if err != nil && err != io.EOF {
+				return s, err
+			}
+			var count uint64
+			hash, count, err = f.parseCountRecord(record)
+			if err != nil {
+				return s, fmt.Errorf("record %d: %w", s.Records+1, err)
+			}
+			s.TotalCount += count
+			if count > s.MaxCount {
+				s.MaxCount = count
+			}
+		} else {
+			n, err := io.ReadFull(br, buf)
+			s.Bytes += int64(n)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return s, err
+			}
+			hash = buf[:f.HashLen]
+		}
+		if s.Records == 0 {
+			s.First = string(hash)
+		}
+		last = append(last[:0], hash...)
+		s.Records++
+	}
+	s.Last = string(last)
+	return s, nil
+}
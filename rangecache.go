@@ -0,0 +1,100 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// rangeCache keeps the responses of the most recently requested prefixes, so
+// popular ones don't have to be searched for again, and counts how often it
+// had them.
+type rangeCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of *cachedRange, most recently used first
+	entries map[string]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+type cachedRange struct {
+	prefix string
+	body   []byte
+}
+
+// newRangeCache returns a cache of the responses of up to size prefixes.
+func newRangeCache(size int) *rangeCache {
+	return &rangeCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the cached response for prefix, if there is one.
+func (c *rangeCache) get(prefix string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[prefix]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*cachedRange).body, true
+}
+
+// add caches the response for prefix, dropping the least recently used one if
+// the cache is full.
+func (c *rangeCache) add(prefix string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[prefix]; ok {
+		e.Value.(*cachedRange).body = body
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[prefix] = c.order.PushFront(&cachedRange{prefix, body})
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*cachedRange).prefix)
+	}
+}
+
+// purge drops all cached responses, keeping the counts, and returns how many
+// there were.
+func (c *rangeCache) purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.order.Len()
+	c.order.Init()
+	clear(c.entries)
+	return n
+}
+
+// writeMetrics writes the hit and miss counts, the hit rate and the number of
+// cached prefixes in the Prometheus text format.
+func (c *rangeCache) writeMetrics(w io.Writer) {
+	c.mu.Lock()
+	hits, misses, entries := c.hits, c.misses, c.order.Len()
+	c.mu.Unlock()
+	var rate float64
+	if hits+misses > 0 {
+		rate = float64(hits) / float64(hits+misses)
+	}
+	fmt.Fprintf(w, "# HELP pwned_range_cache_hits_total Range requests answered from the cache.\n")
+	fmt.Fprintf(w, "# TYPE pwned_range_cache_hits_total counter\n")
+	fmt.Fprintf(w, "pwned_range_cache_hits_total %d\n", hits)
+	fmt.Fprintf(w, "# HELP pwned_range_cache_misses_total Range requests that had to search the list.\n")
+	fmt.Fprintf(w, "# TYPE pwned_range_cache_misses_total counter\n")
+	fmt.Fprintf(w, "pwned_range_cache_misses_total %d\n", misses)
+	fmt.Fprintf(w, "# HELP pwned_range_cache_hit_rate Fraction of range requests answered from the cache.\n")
+	fmt.Fprintf(w, "# TYPE pwned_range_cache_hit_rate gauge\n")
+	fmt.Fprintf(w, "pwned_range_cache_hit_rate %g\n", rate)
+	fmt.Fprintf(w, "# HELP pwned_range_cache_entries Prefixes in the cache.\n")
+	fmt.Fprintf(w, "# TYPE pwned_range_cache_entries gauge\n")
+	fmt.Fprintf(w, "pwned_range_cache_entries %d\n", entries)
+	fmt.Fprintf(w, "# HELP pwned_range_cache_size Maximum number of prefixes in the cache.\n")
+	fmt.Fprintf(w, "# TYPE pwned_range_cache_size gauge\n")
+	fmt.Fprintf(w, "pwned_range_cache_size %d\n", c.size)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// remoteClient is the HTTP client lists given with search --url are read
+// with.
+var remoteClient = &http.Client{Timeout: time.Minute}
+
+// isURL reports whether filename is an HTTP(S) URL instead of a file.
+func isURL(filename string) bool {
+	return strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://")
+}
+
+// httpReaderAt reads a list on a web server or object store with a range
+// request for every read, so it can be searched without downloading it.
+type httpReaderAt struct {
+	ctx  context.Context
+	url  string
+	size int64
+}
+
+// openURL asks url for its size with a HEAD request, which is kept for the
+// reads after it.
+func openURL(ctx context.Context, url string) (*httpReaderAt, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "pwned")
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	if resp.Header.Get("Accept-Ranges") == "none" {
+		return nil, errors.New("the server doesn't support range requests")
+	}
+	if resp.ContentLength < 0 {
+		return nil, errors.New("the server didn't send a Content-Length")
+	}
+	return &httpReaderAt{ctx: ctx, url: url, size: resp.ContentLength}, nil
+}
+
+// Size returns the size of the list, as the HEAD request reported it.
+func (r *httpReaderAt) Size() int64 {
+	return r.size
+}
+
+// Close does nothing, as every read is a request of its own.
+func (r *httpReaderAt) Close() error {
+	return nil
+}
+
+// ReadAt requests the len(p) bytes at off. Like for files, reading past the
+// end returns what's there and io.EOF.
+func (r *httpReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := int64(len(p))
+	if off+n > r.size {
+		n = r.size - off
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "pwned")
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return 0, errors.New("the server doesn't support range requests")
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, errors.New(resp.Status)
+	}
+	read, err := io.ReadFull(resp.Body, p[:n])
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return read, fmt.Errorf("range request returned %d of %d bytes", read, n)
+	}
+	if err != nil {
+		return read, err
+	}
+	if read < len(p) {
+		return read, io.EOF
+	}
+	return read, nil
+}
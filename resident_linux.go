@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// residentBytes returns how many of the first size bytes of f are in the page
+// cache, by mapping them and asking the kernel with mincore.
+func residentBytes(f *os.File, size int64) (int64, error) {
+	if size == 0 {
+		return 0, nil
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Munmap(data)
+	pageSize := int64(os.Getpagesize())
+	vec := make([]byte, (size+pageSize-1)/pageSize)
+	_, _, errno := unix.Syscall(unix.SYS_MINCORE, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(unsafe.Pointer(&vec[0])))
+	if errno != 0 {
+		return 0, errno
+	}
+	var pages int64
+	for _, v := range vec {
+		// The lowest bit is set for pages that are resident.
+		pages += int64(v & 1)
+	}
+	return min(pages*pageSize, size), nil
+}
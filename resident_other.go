@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func residentBytes(f *os.File, size int64) (int64, error) {
+	return 0, errors.New("not supported on this platform")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// scanWordlist hashes every password in wordlist, one per line in encoding
+// as decodeInput takes it, and looks up all of them in one pass over every
+// list in filenames, as the hashes are sorted. The passwords found are
+// written to w in the order of the wordlist, followed by a tab and their
+// count if withCount is set. It returns the number of passwords found and
+// how many there were.
+func scanWordlist(ctx context.Context, w io.Writer, wordlist, encoding string, filenames []string, withCount bool, format pwnedlist.Format) (int, int, error) {
+	words, err := readWords(wordlist, encoding)
+	if err != nil {
+		return 0, 0, err
+	}
+	type candidate struct {
+		hash []byte
+		word int
+	}
+	candidates := make([]candidate, len(words))
+	for i, word := range words {
+		hash := pwnedlist.HashPassword(word)
+		if format == pwnedlist.NTLM {
+			hash = pwnedlist.HashPasswordNTLM(word)
+		}
+		candidates[i] = candidate{[]byte(hash), i}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return bytes.Compare(candidates[i].hash, candidates[j].hash) < 0
+	})
+	hashes := make([][]byte, len(candidates))
+	for i, c := range candidates {
+		hashes[i] = c.hash
+	}
+	// Lists with counts set counts, where 0 means the hash wasn't found, as
+	// they have no such counts, and the others set found.
+	counts := make([]uint64, len(hashes))
+	found := make([]bool, len(hashes))
+	for _, filename := range filenames {
+		if err := scanWordlistFile(ctx, filename, hashes, found, counts, withCount, format); err != nil {
+			return 0, 0, fmt.Errorf("searching file %q: %v", filename, err)
+		}
+	}
+	wordCounts := make([]uint64, len(words))
+	wordFound := make([]bool, len(words))
+	for i, c := range candidates {
+		wordFound[c.word] = found[i] || counts[i] > 0
+		wordCounts[c.word] = counts[i]
+	}
+	bw := bufio.NewWriter(w)
+	hits := 0
+	for i, word := range words {
+		if !wordFound[i] {
+			continue
+		}
+		hits++
+		if withCount {
+			fmt.Fprintf(bw, "%s\t%d\n", word, wordCounts[i])
+		} else {
+			fmt.Fprintln(bw, word)
+		}
+	}
+	return hits, len(words), bw.Flush()
+}
+
+func scanWordlistFile(ctx context.Context, filename string, hashes [][]byte, found []bool, counts []uint64, withCount bool, format pwnedlist.Format) error {
+	r, err := openStream(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cr := pwnedlist.ContextReader(ctx, r)
+	if withCount {
+		return format.SearchManyCounts(cr, hashes, counts)
+	}
+	return format.SearchMany(cr, hashes, found, false)
+}
+
+// readWords reads one password per line from filename, or stdin for "-", in
+// encoding, and returns them without duplicates, in the order they first
+// appear in. Only the line endings are stripped, as spaces can be part of a
+// password, and empty lines are skipped.
+func readWords(filename, encoding string) ([]string, error) {
+	f := os.Stdin
+	if filename != "-" {
+		var err error
+		if f, err = os.Open(filename); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+	r, err := decodeInput(f, encoding)
+	if err != nil {
+		return nil, err
+	}
+	var words []string
+	seen := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		word := strings.TrimSuffix(s.Text(), "\r")
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading %q: %v", filename, err)
+	}
+	return words, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// openFiles returns the number of open file descriptors of the process, and
+// skips the test on platforms without /proc/self/fd.
+func openFiles(t *testing.T) int {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("can't count open files: %v", err)
+	}
+	return len(fds)
+}
+
+// writeTestFile writes data to name in dir, and returns its path.
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSearchFilesAreClosed(t *testing.T) {
+	var list, counts bytes.Buffer
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&list, "%040X\r\n", i)
+		fmt.Fprintf(&counts, "%040X:%d\r\n", i, i+1)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(list.Bytes())
+	zw.Close()
+	dir := t.TempDir()
+	plain := writeTestFile(t, dir, "list.txt", list.Bytes())
+	gzipped := writeTestFile(t, dir, "list.txt.gz", gz.Bytes())
+	bom := writeTestFile(t, dir, "bom.txt", append([]byte(pwnedlist.UTF8BOM), list.Bytes()...))
+	short := writeTestFile(t, dir, "short.txt", list.Bytes()[:list.Len()-1])
+	countList := writeTestFile(t, dir, "counts.txt", counts.Bytes())
+
+	ctx := context.Background()
+	found, missing := fmt.Sprintf("%040X", 42), fmt.Sprintf("%040X", 1000)
+	searches := []struct {
+		name   string
+		search func(hash string) error
+	}{
+		{"mmap", func(hash string) error {
+			_, _, err := searchFile(ctx, plain, hash, true, false, false, nil, pwnedlist.SHA1, nil)
+			return err
+		}},
+		{"ReadAt", func(hash string) error {
+			_, _, err := searchFile(ctx, plain, hash, false, false, false, nil, pwnedlist.SHA1, nil)
+			return err
+		}},
+		{"interpolation", func(hash string) error {
+			_, _, err := searchFile(ctx, plain, hash, true, true, false, nil, pwnedlist.SHA1, nil)
+			return err
+		}},
+		{"BOM", func(hash string) error {
+			_, _, err := searchFile(ctx, bom, hash, true, false, true, nil, pwnedlist.SHA1, nil)
+			return err
+		}},
+		{"gzip", func(hash string) error {
+			_, _, err := searchFile(ctx, gzipped, hash, true, false, false, nil, pwnedlist.SHA1, nil)
+			return err
+		}},
+		{"incomplete", func(hash string) error {
+			_, _, err := searchFile(ctx, short, hash, true, false, false, nil, pwnedlist.SHA1, nil)
+			return err
+		}},
+		{"count", func(hash string) error {
+			_, _, err := searchCountFile(ctx, countList, hash, false, pwnedlist.SHA1, nil)
+			return err
+		}},
+	}
+	for _, s := range searches {
+		t.Run(s.name, func(t *testing.T) {
+			before := openFiles(t)
+			for i := 0; i < 200; i++ {
+				// The incomplete list fails either way.
+				if err := s.search(found); err != nil && s.name != "incomplete" {
+					t.Fatalf("search for %s: %v", found, err)
+				}
+				s.search(missing)
+			}
+			if after := openFiles(t); after > before {
+				t.Errorf("%d files open after 400 searches, %d before", after, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// rangeServer answers k-anonymity range queries like the Pwned Passwords API:
+// GET /range/ABCDE returns the suffixes of all hashes starting with ABCDE.
+type rangeServer struct {
+	f         *os.File
+	withCount bool
+	// cache is nil without serve --cache, and metrics without --metrics.
+	cache   *rangeCache
+	metrics *serverMetrics
+
+	// reads holds a token for every read of the list in progress, and is nil
+	// without serve --max-concurrent. At most queue requests wait for one.
+	reads   chan struct{}
+	queue   int64
+	waiting atomic.Int64
+
+	mu      sync.Mutex
+	size    int64
+	modTime time.Time
+}
+
+// serveOptions are the flags of the serve command.
+type serveOptions struct {
+	withCount bool
+	// cacheSize is the number of cached prefixes, 0 for no cache.
+	cacheSize int
+	metrics   bool
+	// maxConcurrent is the number of list reads at the same time, 0 for no
+	// limit, and queue the number of requests that wait for a turn.
+	maxConcurrent, queue int
+	// allowFullHash serves /check/ for full hashes.
+	allowFullHash bool
+	// warm reads the whole list before serving.
+	warm bool
+}
+
+// shutdownTimeout is how long serve waits for requests in progress when it's
+// stopped.
+const shutdownTimeout = 30 * time.Second
+
+// serve answers range queries on addr until ctx is done, and then waits for
+// the requests in progress to finish.
+func serve(ctx context.Context, addr, filename string, opts serveOptions) error {
+	gzipped, err := isGzip(filename)
+	if err != nil {
+		return err
+	}
+	if gzipped {
+		return errors.New("can't binary search a gzipped list, decompress it first")
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if opts.warm {
+		if err := warmList(f, fi.Size()); err != nil {
+			return err
+		}
+	}
+	s := &rangeServer{f: f, size: fi.Size(), modTime: fi.ModTime(), withCount: opts.withCount}
+	mux := http.NewServeMux()
+	mux.Handle("/range/", s)
+	if opts.allowFullHash {
+		mux.HandleFunc("/check/", s.serveCheck)
+	}
+	if opts.cacheSize > 0 {
+		s.cache = newRangeCache(opts.cacheSize)
+	}
+	if opts.maxConcurrent > 0 {
+		s.reads = make(chan struct{}, opts.maxConcurrent)
+		s.queue = int64(opts.queue)
+	}
+	if opts.metrics {
+		s.metrics = newServerMetrics()
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			s.metrics.write(w)
+			if s.cache != nil {
+				s.cache.writeMetrics(w)
+			}
+		})
+	}
+	srv := &http.Server{Addr: addr, Handler: mux}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	log.Printf("serving %q on %s", filename, addr)
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Printf("shutting down, waiting for requests in progress")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// warmList reads the size bytes of the list in f from start to end, which is
+// a lot faster than the random reads of cold requests, so the page cache has
+// them before the first request comes in. It logs how long that took and how
+// much of the list the page cache kept, which is less than all of it if there
+// isn't enough memory.
+func warmList(f *os.File, size int64) error {
+	start := time.Now()
+	if _, err := io.Copy(io.Discard, io.NewSectionReader(f, 0, size)); err != nil {
+		return fmt.Errorf("warming up the list: %v", err)
+	}
+	took := time.Since(start).Round(time.Millisecond)
+	mib := float64(size) / (1 << 20)
+	resident, err := residentBytes(f, size)
+	if err != nil {
+		log.Printf("read %.1f MiB of the list in %v, resident size unknown: %v", mib, took, err)
+		return nil
+	}
+	percent := 100.0
+	if size > 0 {
+		percent = float64(resident) / float64(size) * 100
+	}
+	log.Printf("read %.1f MiB of the list in %v, %.1f MiB (%.0f%%) is resident", mib, took, float64(resident)/(1<<20), percent)
+	return nil
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.metrics == nil {
+		s.serveRange(w, r)
+		return
+	}
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+	records := s.serveRange(sw, r)
+	s.metrics.observe(sw.code, time.Since(start), records)
+}
+
+// serveRange answers a range query, and returns the number of records in the
+// response, or -1 if it has no records.
+func (s *rangeServer) serveRange(w http.ResponseWriter, r *http.Request) int {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return -1
+	}
+	prefix := strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/range/"))
+	if len(prefix) != 5 || !pwnedlist.IsHex([]byte(prefix)) {
+		http.Error(w, "The hash prefix was not in a valid format", http.StatusBadRequest)
+		return -1
+	}
+	// The list isn't expected to change while serving, so neither are the
+	// responses, apart from their padding.
+	w.Header().Set("Cache-Control", "public, max-age=2678400")
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Vary", "Add-Padding")
+	if r.Method == http.MethodHead {
+		return -1
+	}
+	padding := strings.EqualFold(r.Header.Get("Add-Padding"), "true")
+	if s.cache == nil && !padding {
+		if !s.acquire(r.Context()) {
+			unavailable(w)
+			return -1
+		}
+		defer s.release()
+		records, err := s.writeRange(r.Context(), w, prefix, s.listSize())
+		if err != nil {
+			log.Printf("range %s: %v", prefix, err)
+		}
+		return records
+	}
+	var body []byte
+	var records int
+	cached := false
+	size := s.listSize()
+	if s.cache != nil {
+		body, cached = s.cache.get(prefix)
+		records = bytes.Count(body, []byte("\n"))
+	}
+	if !cached {
+		if !s.acquire(r.Context()) {
+			unavailable(w)
+			return -1
+		}
+		defer s.release()
+		var buf bytes.Buffer
+		var err error
+		records, err = s.writeRange(r.Context(), &buf, prefix, size)
+		if err != nil {
+			log.Printf("range %s: %v", prefix, err)
+			http.Error(w, "error reading the list", http.StatusInternalServerError)
+			return -1
+		}
+		body = buf.Bytes()
+		if s.cache != nil {
+			s.cache.add(prefix, body)
+		}
+	}
+	if padding {
+		body = padRange(body, records, s.withCount)
+	}
+	w.Write(body)
+	return records
+}
+
+// checkResponse is the body of a /check/ response.
+type checkResponse struct {
+	Found bool   `json:"found"`
+	Count uint64 `json:"count"`
+}
+
+// serveCheck answers GET /check/<hash> with whether the full hash is in the
+// list, and its count. Unlike a range query, this tells the server which hash
+// the client is after, so it's only served with --allow-full-hash.
+func (s *rangeServer) serveCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hash, err := pwnedlist.SHA1.ParseHash(strings.TrimPrefix(r.URL.Path, "/check/"))
+	if err != nil {
+		http.Error(w, "The hash was not in a valid format", http.StatusBadRequest)
+		return
+	}
+	// The path is the hash, so the response isn't kept by caches either.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if !s.acquire(r.Context()) {
+		unavailable(w)
+		return
+	}
+	defer s.release()
+	size := s.listSize()
+	list := pwnedlist.ContextReaderAt(r.Context(), s.f)
+	var res checkResponse
+	if s.withCount {
+		_, res.Count, err = pwnedlist.SearchCount(list, size, hash)
+	} else {
+		_, err = pwnedlist.Search(list, size, hash)
+	}
+	switch {
+	case errors.Is(err, pwnedlist.ErrNotFound):
+	case err != nil:
+		log.Printf("check: %v", err)
+		http.Error(w, "error reading the list", http.StatusInternalServerError)
+		return
+	default:
+		res.Found = true
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// writeRange writes the suffixes of the hashes starting with prefix to w, in
+// the list of size bytes, and returns how many it wrote.
+func (s *rangeServer) writeRange(ctx context.Context, w io.Writer, prefix string, size int64) (int, error) {
+	bw := bufio.NewWriter(w)
+	records := 0
+	err := pwnedlist.Range(pwnedlist.ContextReaderAt(ctx, s.f), size, prefix, s.withCount, func(hash []byte, count uint64) error {
+		bw.Write(hash[5:])
+		if s.withCount {
+			bw.WriteByte(':')
+			bw.WriteString(strconv.FormatUint(count, 10))
+		}
+		_, err := bw.WriteString("\r\n")
+		records++
+		return err
+	})
+	if err != nil {
+		return records, err
+	}
+	return records, bw.Flush()
+}
+
+// acquire waits for a turn to read the list. It reports false if queue
+// requests are waiting already, or if the request is canceled while waiting.
+func (s *rangeServer) acquire(ctx context.Context) bool {
+	if s.reads == nil {
+		return true
+	}
+	select {
+	case s.reads <- struct{}{}:
+		return true
+	default:
+	}
+	if s.waiting.Add(1) > s.queue {
+		s.waiting.Add(-1)
+		return false
+	}
+	defer s.waiting.Add(-1)
+	select {
+	case s.reads <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release ends a turn started by acquire.
+func (s *rangeServer) release() {
+	if s.reads != nil {
+		<-s.reads
+	}
+}
+
+// unavailable tells the client the server is too busy to read the list.
+func unavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	w.Header().Del("Cache-Control")
+	http.Error(w, "too many requests in progress, try again later", http.StatusServiceUnavailable)
+}
+
+// listSize returns the size of the list to search. With a cache it's
+// refreshed first, so that the cached responses are of the same list.
+func (s *rangeServer) listSize() int64 {
+	if s.cache != nil {
+		return s.refresh()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// refresh drops the cached responses if the modification time of the list
+// changed since it was last looked at, and returns its current size.
+func (s *rangeServer) refresh() int64 {
+	fi, err := s.f.Stat()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		log.Printf("stat list: %v", err)
+		return s.size
+	}
+	if !fi.ModTime().Equal(s.modTime) {
+		log.Printf("list changed, dropped %d cached ranges", s.cache.purge())
+		s.size, s.modTime = fi.Size(), fi.ModTime()
+	}
+	return s.size
+}
+
+// paddedRecords is the number of lines a range response is padded to when
+// the client sends Add-Padding: true.
+const paddedRecords = 1000
+
+// padRange returns the range response body of records lines with random
+// suffixes added, up to paddedRecords lines, in sorted order. Like the Pwned
+// Passwords API does for Add-Padding, this keeps the size of a response from
+// telling an eavesdropper on an encrypted connection how many hashes share
+// the prefix, which would narrow down the prefix and so the password. With
+// withCount the padding has a count of 0, which clients drop. Without it the
+// padding is bare suffixes like the real records, as lines of another length
+// would give their number away. A client looking for its own suffix doesn't
+// come across a random one.
+func padRange(body []byte, records int, withCount bool) []byte {
+	if records >= paddedRecords {
+		return body
+	}
+	lines := make([][]byte, 0, paddedRecords)
+	for line := range bytes.SplitSeq(bytes.TrimSuffix(body, []byte("\r\n")), []byte("\r\n")) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	suffixLen := pwnedlist.SHA1.HashLen - 5
+	random := make([]byte, (suffixLen+1)/2)
+	for len(lines) < paddedRecords {
+		rand.Read(random)
+		suffix := strings.ToUpper(hex.EncodeToString(random))[:suffixLen]
+		if withCount {
+			suffix += ":0"
+		}
+		lines = append(lines, []byte(suffix))
+	}
+	slices.SortFunc(lines, bytes.Compare)
+	return append(bytes.Join(lines, []byte("\r\n")), "\r\n"...)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a rangeServer with a cache for a list of records
+// hashes with the prefixes 00000 to 000FF.
+func newTestServer(t *testing.T, records int) *rangeServer {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(&b, "%05X%035X\r\n", i%256, i)
+	}
+	name := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(name, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &rangeServer{f: f, size: fi.Size(), modTime: fi.ModTime(), cache: newRangeCache(16)}
+}
+
+// touchList keeps changing the modification time of the list of s and
+// refreshing it until done is closed, so the size of the list is written
+// while requests are in progress.
+func touchList(t *testing.T, s *rangeServer, done <-chan struct{}) {
+	mtime := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		mtime = mtime.Add(time.Second)
+		if err := os.Chtimes(s.f.Name(), mtime, mtime); err != nil {
+			t.Error(err)
+			return
+		}
+		s.refresh()
+		runtime.Gosched()
+	}
+}
+
+// TestServeRangeRefresh is meant to be run with -race, which reports requests
+// that read the size of the list while a refresh changes it.
+func TestServeRangeRefresh(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+	s := newTestServer(t, 256)
+	done := make(chan struct{})
+	touched := make(chan struct{})
+	go func() {
+		defer close(touched)
+		touchList(t, s, done)
+	}()
+	defer func() {
+		close(done)
+		<-touched
+	}()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/range/%05X", (g*100+i)%256), nil)
+				if i%10 == 0 {
+					req.Header.Set("Add-Padding", "true")
+				}
+				rec := httptest.NewRecorder()
+				s.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Errorf("%s: status %d", req.URL.Path, rec.Code)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestServeCheckRefresh is TestServeRangeRefresh for /check/.
+func TestServeCheckRefresh(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+	s := newTestServer(t, 256)
+	done := make(chan struct{})
+	touched := make(chan struct{})
+	go func() {
+		defer close(touched)
+		touchList(t, s, done)
+	}()
+	defer func() {
+		close(done)
+		<-touched
+	}()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				n := (g*100 + i) % 512
+				req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/check/%05X%035X", n%256, n), nil)
+				rec := httptest.NewRecorder()
+				s.serveCheck(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Errorf("%s: status %d", req.URL.Path, rec.Code)
+					return
+				}
+				if want := fmt.Sprintf(`"found":%v`, n < 256); !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("%s: got %s, want %s", req.URL.Path, rec.Body, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// sortFile sorts the list in in to out, and checks the result. Either can be
+// "-", for stdin and stdout.
+func sortFile(in, out string, memory int, tmpDir string, format pwnedlist.Format) error {
+	r, err := openStream(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := createAtomic(out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	n, err := format.Sort(w, r, memory, tmpDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "sorted %d records\n", n)
+	if out == "-" {
+		return nil
+	}
+	// The list is checked before it's renamed into place, so a bad sort
+	// doesn't replace anything.
+	_, err = checkList(context.Background(), tempName(out), pwnedlist.CheckOptions{
+		CheckOrder: true,
+		Workers:    1,
+		BufferSize: 4 << 20,
+		Format:     format,
+	}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("checking %q: %v", out, err)
+	}
+	return w.Commit()
+}
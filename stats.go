@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/loeyt/pwned/pwnedlist"
+)
+
+// statsResult is the summary of a single file, as printed by the stats
+// command.
+type statsResult struct {
+	File    string `json:"file"`
+	Size    int64  `json:"size"`
+	Records int64  `json:"records"`
+	First   string `json:"first,omitempty"`
+	Last    string `json:"last,omitempty"`
+	// Aligned reports whether the size is a multiple of the record size,
+	// only for fixed-width lists.
+	Aligned    *bool   `json:"aligned,omitempty"`
+	TotalCount *uint64 `json:"total_count,omitempty"`
+	MaxCount   *uint64 `json:"max_count,omitempty"`
+}
+
+// statsFile streams filename, which can be "-" or gzipped, and summarizes
+// it.
+func statsFile(filename string, withCount bool, format pwnedlist.Format) (statsResult, error) {
+	r, err := openStream(filename)
+	if err != nil {
+		return statsResult{}, err
+	}
+	defer r.Close()
+	s, err := format.Summarize(r, withCount)
+	if err != nil {
+		return statsResult{}, err
+	}
+	res := statsResult{
+		File:    filename,
+		Size:    s.Bytes,
+		Records: s.Records,
+		First:   s.First,
+		Last:    s.Last,
+	}
+	if withCount {
+		res.TotalCount, res.MaxCount = &s.TotalCount, &s.MaxCount
+	} else {
+		aligned := s.Bytes%int64(format.RecordSize) == 0
+		res.Aligned = &aligned
+	}
+	return res, nil
+}
+
+func (res statsResult) print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "file\t%s\n", res.File)
+	fmt.Fprintf(w, "size\t%d bytes\n", res.Size)
+	fmt.Fprintf(w, "records\t%d\n", res.Records)
+	if res.Aligned != nil {
+		aligned := "yes"
+		if !*res.Aligned {
+			aligned = "no, the last record is incomplete"
+		}
+		fmt.Fprintf(w, "aligned\t%s\n", aligned)
+	}
+	fmt.Fprintf(w, "first hash\t%s\n", res.First)
+	fmt.Fprintf(w, "last hash\t%s\n", res.Last)
+	if res.TotalCount != nil {
+		fmt.Fprintf(w, "total count\t%d\n", *res.TotalCount)
+		fmt.Fprintf(w, "max count\t%d\n", *res.MaxCount)
+	}
+	w.Flush()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sha256File returns the SHA-256 digest of filename, or of stdin for "-", as
+// stored: gzipped files aren't decompressed.
+func sha256File(filename string) (string, error) {
+	f := os.Stdin
+	if filename != "-" {
+		var err error
+		f, err = os.Open(filename)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseSHA256 returns expected in lowercase, if it's a SHA-256 digest in
+// hexadecimal notation.
+func parseSHA256(expected string) (string, error) {
+	expected = strings.ToLower(expected)
+	if _, err := hex.DecodeString(expected); err != nil || len(expected) != 2*sha256.Size {
+		return "", fmt.Errorf("%q isn't a SHA-256 digest of %d hexadecimal characters", expected, 2*sha256.Size)
+	}
+	return expected, nil
+}
+
+// checksumError reports a file whose digest isn't the expected one.
+type checksumError struct {
+	Digest, Expected string
+}
+
+func (e *checksumError) Error() string {
+	return fmt.Sprintf("SHA-256 is %s, expected %s", e.Digest, e.Expected)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMagic are the first bytes of every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+const (
+	// skippableMagic starts the skippable frame with the seek table of a
+	// seekable zstd file.
+	skippableMagic = 0x184D2A5E
+	// seekableMagic ends the seek table.
+	seekableMagic = 0x8F92EAB1
+	// seekTableFooter is the size of the end of the seek table: the number
+	// of frames, the descriptor and seekableMagic.
+	seekTableFooter = 9
+)
+
+// isZstd reports whether filename is compressed with zstd, going by its
+// extension or its first bytes. Stdin isn't, it's read as a stream.
+func isZstd(filename string) (bool, error) {
+	if filename == "-" {
+		return false, nil
+	}
+	if filepath.Ext(filename) == ".zst" {
+		return true, nil
+	}
+	return startsWith(filename, zstdMagic)
+}
+
+// zstdReaderAt reads a zstd file in the seekable format, which is made of
+// independent frames and ends with a table of their sizes, as if it were
+// decompressed. Reads only decompress the frames they need, so the list in it
+// can be binary searched.
+type zstdReaderAt struct {
+	f   *os.File
+	dec *zstd.Decoder
+	// compressed and decompressed have the offsets at which every frame
+	// starts, in the file and in the decompressed data, and their sizes
+	// at the end.
+	compressed   []int64
+	decompressed []int64
+
+	mu sync.Mutex
+	// frame is the number of the frame in buf, or -1.
+	frame int
+	buf   []byte
+}
+
+// openSeekableZstd opens filename and reads its seek table. It's an error for
+// the file not to have one, as it would have to be decompressed from the
+// start.
+func openSeekableZstd(filename string) (*zstdReaderAt, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := readSeekTable(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%v, decompress it (zstd -d) or recompress it in the seekable format", err)
+	}
+	return r, nil
+}
+
+func readSeekTable(f *os.File) (*zstdReaderAt, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	noTable := errors.New("zstd file has no seek table")
+	if size < 8+seekTableFooter {
+		return nil, noTable
+	}
+	footer := make([]byte, seekTableFooter)
+	if _, err := f.ReadAt(footer, size-seekTableFooter); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return nil, noTable
+	}
+	frames := int64(binary.LittleEndian.Uint32(footer))
+	descriptor := footer[4]
+	if descriptor&0x7c != 0 {
+		return nil, errors.New("zstd seek table has reserved bits set")
+	}
+	entrySize := int64(8)
+	if descriptor&0x80 != 0 {
+		// Every entry has a checksum too, which isn't needed to seek.
+		entrySize = 12
+	}
+	tableSize := frames*entrySize + seekTableFooter
+	if tableSize+8 > size {
+		return nil, errors.New("zstd seek table is bigger than the file")
+	}
+	table := make([]byte, tableSize+8)
+	if _, err := f.ReadAt(table, size-tableSize-8); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(table) != skippableMagic || int64(binary.LittleEndian.Uint32(table[4:])) != tableSize {
+		return nil, errors.New("corrupt zstd seek table")
+	}
+	r := &zstdReaderAt{
+		f:            f,
+		compressed:   make([]int64, frames+1),
+		decompressed: make([]int64, frames+1),
+		frame:        -1,
+	}
+	entries := table[8:]
+	for i := int64(0); i < frames; i++ {
+		e := entries[i*entrySize:]
+		r.compressed[i+1] = r.compressed[i] + int64(binary.LittleEndian.Uint32(e))
+		r.decompressed[i+1] = r.decompressed[i] + int64(binary.LittleEndian.Uint32(e[4:]))
+	}
+	if r.compressed[frames] != size-tableSize-8 {
+		return nil, errors.New("zstd seek table doesn't match the frames in the file")
+	}
+	r.dec, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Size returns the size of the decompressed data.
+func (r *zstdReaderAt) Size() int64 {
+	return r.decompressed[len(r.decompressed)-1]
+}
+
+// ReadAt reads the decompressed data at off, decompressing the frames it's
+// in. The last frame read is kept, as probes near the end of a binary search
+// tend to land in the same one.
+func (r *zstdReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for n < len(p) {
+		if off >= r.Size() {
+			return n, io.EOF
+		}
+		// The frame that off is in is the last one starting at or before it.
+		i := sort.Search(len(r.decompressed)-1, func(i int) bool { return r.decompressed[i+1] > off })
+		if err := r.load(i); err != nil {
+			return n, err
+		}
+		c := copy(p[n:], r.buf[off-r.decompressed[i]:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// load decompresses frame i into r.buf, unless it's there already.
+func (r *zstdReaderAt) load(i int) error {
+	if r.frame == i {
+		return nil
+	}
+	src := make([]byte, r.compressed[i+1]-r.compressed[i])
+	if _, err := r.f.ReadAt(src, r.compressed[i]); err != nil {
+		return err
+	}
+	r.frame = -1
+	buf, err := r.dec.DecodeAll(src, r.buf[:0])
+	if err != nil {
+		return fmt.Errorf("zstd frame %d: %v", i+1, err)
+	}
+	if int64(len(buf)) != r.decompressed[i+1]-r.decompressed[i] {
+		return fmt.Errorf("zstd frame %d has %d bytes instead of the %d in the seek table", i+1, len(buf), r.decompressed[i+1]-r.decompressed[i])
+	}
+	r.frame, r.buf = i, buf
+	return nil
+}
+
+// Close closes the file.
+func (r *zstdReaderAt) Close() error {
+	r.dec.Close()
+	return r.f.Close()
+}